@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultBulkPageSize is the number of documents fetched per page by a bulk translation.
+const DefaultBulkPageSize = 50
+
+// BulkRequest translates every document matching a GROQ filter.
+type BulkRequest struct {
+	Filter        string             // GROQ filter selecting the documents, e.g. _type == "article"
+	FromLang      string             // Language of the source documents
+	ToLangs       []string           // Languages to translate to
+	InputElements []InputElement     // Selectors of the fields to translate, empty to use the translation schema
+	Dataset       string             // Sanity dataset, defaults to the configured one
+	GlossaryID    string             // Glossary to apply with a single language in ToLangs
+	Glossaries    map[string]string  // Glossary per language of ToLangs, the others default to the one stored for their pair
+	Options       TranslationOptions // Provider options applied to every field
+	PortableText  bool               // Translate Portable Text blocks as a whole
+	LeafRules     LeafRules          // Overrides of the rules skipping system fields, URLs, emails, colors and slugs
+	Draft         string             // Write the translations into drafts, see SanityDocumentTranslator
+	Merge         bool               // Keep target-language edits, see SanityDocumentTranslator
+	Incremental   bool               // Only translate the fields whose source changed
+	Concurrency   int                // Target languages translated at the same time for each document
+	Force         bool               // Translate documents again even when they already have a translation
+	PageSize      int                // Documents fetched per page, defaults to DefaultBulkPageSize
+	ResumeJobID   string             // Continue a failed or cancelled bulk job from where it stopped
+	Cursor        string             // Start after this _id, e.g. the cursor of a job no longer kept
+	StateFile     string             `json:"-"` // CLI only: file saving the report after each document, resumed when it exists
+}
+
+// BulkOutcome is the result of a bulk translation for one document.
+type BulkOutcome struct {
+	ID        string           `json:"id"`
+	Slug      string           `json:"slug"`
+	Status    string           `json:"status"` // "translated", "partial" (some languages failed), "skipped" or "failed"
+	Error     string           `json:"error,omitempty"`
+	Languages []LanguageResult `json:"languages,omitempty"`
+}
+
+// BulkReport is the progress of a bulk translation. Cursor is the _id of the
+// last document processed before the first failure: a resumed bulk translation
+// starts after it, so that failed and partial documents are tried again.
+type BulkReport struct {
+	Cursor     string        `json:"cursor"`
+	Processed  int           `json:"processed"`
+	Translated int           `json:"translated"`
+	Partial    int           `json:"partial"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	Documents  []BulkOutcome `json:"documents"`
+}
+
+// ValidateBulkRequest checks a bulk request and parses its selectors.
+func ValidateBulkRequest(req *BulkRequest) error {
+	if strings.TrimSpace(req.Filter) == "" {
+		return errors.New("invalid Filter: it must not be empty")
+	}
+	if req.FromLang == "" || len(req.ToLangs) == 0 {
+		return errors.New("invalid languages: FromLang and ToLangs are required")
+	}
+	if req.ResumeJobID != "" && req.Cursor != "" {
+		return errors.New("invalid resume: set either ResumeJobID or Cursor")
+	}
+	if req.PageSize < 0 {
+		return fmt.Errorf("invalid PageSize: %d", req.PageSize)
+	}
+	if err := validateGlossaryID(req.GlossaryID, len(req.ToLangs)); err != nil {
+		return err
+	}
+	return ValidateDocumentRequest(req.documentRequest("", nil))
+}
+
+// documentRequest returns the SanityTranslateDocument request translating slug into targets
+func (req *BulkRequest) documentRequest(slug string, targets []Target) *SanityDocumentTranslator {
+	return &SanityDocumentTranslator{
+		FromLang:      req.FromLang,
+		FromSlug:      slug,
+		Targets:       targets,
+		Concurrency:   req.Concurrency,
+		Dataset:       req.Dataset,
+		GlossaryID:    req.GlossaryID,
+		Glossaries:    req.Glossaries,
+		Options:       req.Options,
+		PortableText:  req.PortableText,
+		LeafRules:     req.LeafRules,
+		Draft:         req.Draft,
+		Merge:         req.Merge,
+		Incremental:   req.Incremental,
+		InputElements: append([]InputElement{}, req.InputElements...),
+	}
+}
+
+// BulkPageQuery returns the query fetching the next page of published source documents
+// matching filter, with the languages they are already translated into
+func BulkPageQuery(filter string) string {
+	return `*[(` + filter + `) && language == $fromLang && !(_id in path("drafts.**")) && _id > $cursor]` +
+		` | order(_id asc) [0...$pageSize] {` +
+		`_id, "slug": slug.current, ` +
+		`"translated": *[_type == "translation.metadata" && references(^._id)][0].translations[]._key}`
+}
+
+// targetSlug derives the slug of a translation from the source slug, replacing
+// its language prefix (/en/page becomes /it/page)
+func targetSlug(slug string, fromLang string, toLang string) string {
+	if strings.HasPrefix(slug, "/"+fromLang+"/") {
+		return "/" + toLang + strings.TrimPrefix(slug, "/"+fromLang)
+	}
+	return "/" + toLang + "/" + strings.TrimPrefix(slug, "/")
+}
+
+// RunBulk pages through the documents matching the filter of req and translates each
+// of them with TranslateDocument, starting after report.Cursor. The report is
+// updated after each document, and progress called when set.
+func RunBulk(ctx context.Context, req *BulkRequest, report *BulkReport, progress func(BulkOutcome)) error {
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultBulkPageSize
+	}
+	report.rewind()
+
+	// The report cursor stays at the first failure, pages move past it
+	after := report.Cursor
+	for {
+		response, err := sanityFor(req.Dataset).Query(BulkPageQuery(req.Filter), map[string]interface{}{
+			"fromLang": req.FromLang,
+			"cursor":   after,
+			"pageSize": pageSize,
+		})
+		if err != nil {
+			fmt.Fprintln(logOutput, "Error fetching bulk translation page after:", after)
+			return err
+		}
+		documents := gjson.Get(response, "result").Array()
+		if len(documents) == 0 {
+			return nil
+		}
+
+		for _, document := range documents {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			outcome := translateBulkDocument(ctx, req, document)
+			after = outcome.ID
+			report.add(outcome)
+			if progress != nil {
+				progress(outcome)
+			}
+		}
+		if len(documents) < pageSize {
+			return nil
+		}
+	}
+}
+
+// translateBulkDocument translates one document of a bulk translation into the
+// languages it is missing, or all of them with Force
+func translateBulkDocument(ctx context.Context, req *BulkRequest, document gjson.Result) BulkOutcome {
+	outcome := BulkOutcome{
+		ID:   document.Get("_id").String(),
+		Slug: document.Get("slug").String(),
+	}
+
+	translated := map[string]bool{}
+	for _, lang := range document.Get("translated").Array() {
+		translated[lang.String()] = true
+	}
+	targets := []Target{}
+	for _, lang := range req.ToLangs {
+		if !translated[lang] || req.Force {
+			targets = append(targets, Target{Lang: lang, Slug: targetSlug(outcome.Slug, req.FromLang, lang)})
+		}
+	}
+
+	switch {
+	case outcome.Slug == "":
+		outcome.Status = "failed"
+		outcome.Error = "document has no slug"
+	case len(targets) == 0:
+		outcome.Status = "skipped"
+	default:
+		result, err := TranslateDocument(ctx, req.documentRequest(outcome.Slug, targets))
+		outcome.Languages = result.Languages
+		outcome.Status = "translated"
+		if err != nil {
+			outcome.Status = "failed"
+			outcome.Error = err.Error()
+			break
+		}
+		failed := []string{}
+		for _, language := range result.Languages {
+			if language.Status == "failed" {
+				failed = append(failed, language.Lang)
+			}
+		}
+		if len(failed) > 0 {
+			outcome.Status = "partial"
+			outcome.Error = "failed translating to " + strings.Join(failed, ", ")
+		}
+	}
+	fmt.Fprintf(logOutput, "Bulk translation of %s: %s\n", outcome.ID, outcome.Status)
+	return outcome
+}
+
+// add records the outcome of a document. The cursor moves past it as long as
+// every document before it succeeded or was skipped.
+func (report *BulkReport) add(outcome BulkOutcome) {
+	caughtUp := len(report.Documents) == 0 || report.Documents[len(report.Documents)-1].ID == report.Cursor
+	if caughtUp && (outcome.Status == "translated" || outcome.Status == "skipped") {
+		report.Cursor = outcome.ID
+	}
+	report.count(outcome, 1)
+	report.Documents = append(report.Documents, outcome)
+}
+
+// count adds delta to the counters of the status of outcome
+func (report *BulkReport) count(outcome BulkOutcome, delta int) {
+	report.Processed += delta
+	switch outcome.Status {
+	case "translated":
+		report.Translated += delta
+	case "partial":
+		report.Partial += delta
+	case "skipped":
+		report.Skipped += delta
+	default:
+		report.Failed += delta
+	}
+}
+
+// rewind drops the outcomes of the documents after the cursor, which a resumed
+// bulk translation processes again
+func (report *BulkReport) rewind() {
+	kept := []BulkOutcome{}
+	for _, outcome := range report.Documents {
+		if outcome.ID > report.Cursor {
+			report.count(outcome, -1)
+			continue
+		}
+		kept = append(kept, outcome)
+	}
+	report.Documents = kept
+}
+
+// snapshot returns a copy of the report safe to publish while the bulk translation goes on
+func (report *BulkReport) snapshot() *BulkReport {
+	copied := *report
+	copied.Documents = append([]BulkOutcome{}, report.Documents...)
+	return &copied
+}
+
+// LoadBulkReport reads the report saved in path, or returns an empty one when it does not exist.
+func LoadBulkReport(path string) (*BulkReport, error) {
+	report := &BulkReport{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("invalid bulk state %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// Save writes the report to path.
+func (report *BulkReport) Save(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
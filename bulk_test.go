@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRunBulk(t *testing.T) {
+	// Documents matching the filter, sorted by _id, with the languages they are translated into
+	documents := []string{
+		`{"_id": "a", "slug": "/en/a", "translated": ["en", "it"]}`,
+		`{"_id": "b", "slug": "/en/b", "translated": null}`,
+		`{"_id": "c", "slug": null, "translated": null}`,
+		`{"_id": "d", "slug": "/en/d", "translated": ["en"]}`,
+	}
+
+	tests := []struct {
+		name          string
+		cursor        string
+		force         bool
+		toLangs       []string
+		wantStatuses  string
+		wantCursor    string   // Stops before the first failure
+		wantTranslate []string // Documents written, by _id
+	}{
+		{name: "SkipTranslated", wantStatuses: "a:skipped b:translated c:failed d:translated", wantCursor: "b", wantTranslate: []string{"b_it", "d_it"}},
+		{name: "Force", force: true, wantStatuses: "a:translated b:translated c:failed d:translated", wantCursor: "b", wantTranslate: []string{"a_it", "b_it", "d_it"}},
+		{name: "Resume", cursor: "b", wantStatuses: "c:failed d:translated", wantCursor: "b", wantTranslate: []string{"d_it"}},
+		{name: "ResumePastFailure", cursor: "c", wantStatuses: "d:translated", wantCursor: "d", wantTranslate: []string{"d_it"}},
+		{name: "PartialFailure", toLangs: []string{"it", "de"}, wantStatuses: "a:failed b:partial c:failed d:partial", wantCursor: "", wantTranslate: []string{"b_it", "d_it"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake *fakeSanity
+			fake = useFakeSanity(t, func(query string) string {
+				switch {
+				case strings.Contains(query, "_id > $cursor"):
+					if !strings.Contains(query, `(_type == "article")`) {
+						t.Errorf("%s: filter missing from %s", tt.name, query)
+					}
+					cursor := strings.Trim(fake.params.Get("$cursor"), `"`)
+					page := []string{}
+					for _, document := range documents {
+						if gjson.Get(document, "_id").String() > cursor && len(page) < 2 {
+							page = append(page, document)
+						}
+					}
+					return "[" + strings.Join(page, ",") + "]"
+				case strings.Contains(query, "translation.metadata"):
+					return `null`
+				}
+				slug := strings.Trim(fake.params.Get("$slug"), `"`)
+				id := strings.TrimPrefix(slug, "/en/")
+				return fmt.Sprintf(`{"_id": "%s", "_type": "article", "slug": {"current": "%s"}, "title": "Title"}`, id, slug)
+			})
+			useFakeTranslator(t).failLang = "de"
+
+			toLangs := tt.toLangs
+			if toLangs == nil {
+				toLangs = []string{"it"}
+			}
+			req := &BulkRequest{
+				Filter:        `_type == "article"`,
+				FromLang:      "en",
+				ToLangs:       toLangs,
+				InputElements: []InputElement{{Path: "title"}},
+				Force:         tt.force,
+				PageSize:      2,
+			}
+			if err := ValidateBulkRequest(req); err != nil {
+				t.Fatalf("%s: ValidateBulkRequest() returned an error: %v", tt.name, err)
+			}
+			report := &BulkReport{Cursor: tt.cursor}
+			if err := RunBulk(context.Background(), req, report, nil); err != nil {
+				t.Fatalf("%s: RunBulk() returned an error: %v", tt.name, err)
+			}
+
+			statuses := []string{}
+			for _, outcome := range report.Documents {
+				statuses = append(statuses, outcome.ID+":"+outcome.Status)
+			}
+			if got := strings.Join(statuses, " "); got != tt.wantStatuses {
+				t.Errorf("%s: outcomes = %s, want %s", tt.name, got, tt.wantStatuses)
+			}
+			if report.Cursor != tt.wantCursor || report.Processed != len(statuses) {
+				t.Errorf("%s: cursor = %q after %d documents", tt.name, report.Cursor, report.Processed)
+			}
+
+			written := []string{}
+			for _, mutation := range fake.mutations {
+				document := gjson.Get(mutation, "mutations.0.createOrReplace")
+				written = append(written, document.Get("_id").String())
+				if document.Get("slug.current").String() != "/it/"+strings.TrimSuffix(document.Get("_id").String(), "_it") {
+					t.Errorf("%s: unexpected slug in %s", tt.name, document.Raw)
+				}
+			}
+			if strings.Join(written, " ") != strings.Join(tt.wantTranslate, " ") {
+				t.Errorf("%s: wrote %v, want %v", tt.name, written, tt.wantTranslate)
+			}
+		})
+	}
+}
+
+func TestBulkReportState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bulk.json")
+
+	report, err := LoadBulkReport(path)
+	if err != nil || report.Cursor != "" {
+		t.Fatalf("LoadBulkReport() of a missing file = %+v, %v", report, err)
+	}
+
+	report.add(BulkOutcome{ID: "a", Status: "translated"})
+	report.add(BulkOutcome{ID: "b", Status: "failed", Error: "boom"})
+	if err := report.Save(path); err != nil {
+		t.Fatalf("Save() returned an error: %v", err)
+	}
+
+	loaded, err := LoadBulkReport(path)
+	if err != nil {
+		t.Fatalf("LoadBulkReport() returned an error: %v", err)
+	}
+	if loaded.Cursor != "a" || loaded.Translated != 1 || loaded.Failed != 1 || len(loaded.Documents) != 2 {
+		t.Errorf("LoadBulkReport() = %+v", loaded)
+	}
+
+	// Resuming drops the outcomes after the cursor, to process them again
+	loaded.add(BulkOutcome{ID: "c", Status: "translated"})
+	loaded.rewind()
+	if loaded.Cursor != "a" || loaded.Processed != 1 || loaded.Failed != 0 || loaded.Translated != 1 || len(loaded.Documents) != 1 {
+		t.Errorf("rewind() = %+v", loaded)
+	}
+
+	os.WriteFile(path, []byte("not json"), 0o644)
+	if _, err := LoadBulkReport(path); err == nil {
+		t.Errorf("LoadBulkReport() of an invalid file should fail")
+	}
+}
+
+func TestTargetSlug(t *testing.T) {
+	tests := []struct {
+		slug string
+		want string
+	}{
+		{slug: "/en/page/about", want: "/it/page/about"},
+		{slug: "/english-page", want: "/it/english-page"},
+		{slug: "about", want: "/it/about"},
+	}
+
+	for _, tt := range tests {
+		if got := targetSlug(tt.slug, "en", "it"); got != tt.want {
+			t.Errorf("targetSlug(%q) = %q, want %q", tt.slug, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const cliUsage = `Usage: SanityTranslator <command> [flags]
+
+Commands:
+  serve             start the HTTP server (default)
+  translate-doc     translate a document into one or more languages
+  translate-field   translate fields of a document into existing translations
+  bulk              translate every document matching a GROQ filter
+  metadata repair   fix the translation.metadata of documents
+
+Run SanityTranslator <command> -h for the flags of a command.
+`
+
+// cliOutput receives the results of the commands. The logs printed along the way
+// go to stderr through logOutput, so that -json output can be piped.
+var cliOutput io.Writer = os.Stdout
+
+// RunCLI runs the command in args and returns the exit code.
+func RunCLI(args []string) int {
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command != "serve" {
+		logOutput = os.Stderr
+	}
+
+	switch command {
+	case "help", "-h", "--help":
+		fmt.Fprint(cliOutput, cliUsage)
+		return 0
+	}
+	if err := setup(); err != nil {
+		return 1
+	}
+
+	switch command {
+	case "serve":
+		return runServeCommand(args)
+	case "translate-doc":
+		return runTranslateDocCommand(args)
+	case "translate-field":
+		return runTranslateFieldCommand(args)
+	case "bulk":
+		return runBulkCommand(args)
+	case "metadata":
+		if len(args) > 0 && args[0] == "repair" {
+			return runMetadataRepairCommand(args[1:])
+		}
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, cliUsage)
+		return 2
+	}
+}
+
+// printResult writes result as indented JSON, or with human when JSON is not requested
+func printResult(asJSON bool, result interface{}, human func(w io.Writer)) {
+	if asJSON {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(cliOutput, string(output))
+		return
+	}
+	human(cliOutput)
+}
+
+// interruptContext returns a context cancelled by Ctrl-C
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// runServeCommand starts the HTTP server.
+func runServeCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8001", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Fprintln(logOutput, "Starting Sanity Translation Service")
+	fmt.Fprintln(logOutput, "")
+	if err := NewRouter().Run(*addr); err != nil {
+		fmt.Fprintln(os.Stderr, "Server stopped:", err)
+		return 1
+	}
+	return 0
+}
+
+// documentFlags registers the flags shared by the commands translating documents
+func documentFlags(flags *flag.FlagSet, txx *SanityDocumentTranslator, selectors *string) {
+	flags.StringVar(selectors, "select", "", "comma separated selectors of the fields to translate, empty to use the translation schema")
+	flags.StringVar(&txx.Dataset, "dataset", "", "Sanity dataset, defaults to SANITY_DATASET")
+	flags.StringVar(&txx.GlossaryID, "glossary", "", "glossary to apply, defaults to the one stored for the language pair")
+	flags.StringVar(&txx.Options.Formality, "formality", "", "default, more, less, prefer_more or prefer_less")
+	flags.StringVar(&txx.Draft, "draft", "", `write into drafts: "replace" or "patch"`)
+	flags.BoolVar(&txx.Merge, "merge", false, "keep target-language edits")
+	flags.BoolVar(&txx.Incremental, "incremental", false, "only translate the fields whose source changed")
+	flags.BoolVar(&txx.PortableText, "portable-text", false, "translate Portable Text blocks as a whole")
+	flags.IntVar(&txx.Concurrency, "concurrency", 0, "target languages translated at the same time")
+}
+
+// runTranslateDocCommand translates a document, like POST /sanity_translate_document.
+func runTranslateDocCommand(args []string) int {
+	var txx SanityDocumentTranslator
+	var toLangs, toSlugs, selectors string
+	var asJSON bool
+
+	flags := flag.NewFlagSet("translate-doc", flag.ContinueOnError)
+	flags.StringVar(&txx.FromLang, "from-lang", "", "language of the source document")
+	flags.StringVar(&txx.FromSlug, "from-slug", "", "slug of the source document")
+	flags.StringVar(&toLangs, "to-lang", "", "comma separated languages to translate to")
+	flags.StringVar(&toSlugs, "to-slug", "", "comma separated slugs of the translations, derived from -from-slug when empty")
+	flags.BoolVar(&txx.FromDraft, "from-draft", false, "translate the draft of the source document when there is one")
+	flags.BoolVar(&txx.DryRun, "dry-run", false, "translate without writing to Sanity")
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	documentFlags(flags, &txx, &selectors)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	langs, slugs := splitList(toLangs), splitList(toSlugs)
+	if txx.FromLang == "" || txx.FromSlug == "" || len(langs) == 0 {
+		fmt.Fprintln(os.Stderr, "-from-lang, -from-slug and -to-lang are required")
+		return 2
+	}
+	if len(slugs) > 0 && len(slugs) != len(langs) {
+		fmt.Fprintln(os.Stderr, "-to-slug needs one slug per -to-lang")
+		return 2
+	}
+	for i, lang := range langs {
+		slug := targetSlug(txx.FromSlug, txx.FromLang, lang)
+		if len(slugs) > 0 {
+			slug = slugs[i]
+		}
+		txx.Targets = append(txx.Targets, Target{Lang: lang, Slug: slug})
+	}
+	for _, selector := range splitList(selectors) {
+		txx.InputElements = append(txx.InputElements, InputElement{Path: selector})
+	}
+
+	if err := ValidateDocumentRequest(&txx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := TranslateDocument(ctx, &txx)
+	printResult(asJSON, result, func(w io.Writer) {
+		for _, language := range result.Languages {
+			fmt.Fprintf(w, "%-10s %s %s %s\n", language.Status, language.Lang, language.ID, language.Error)
+			for _, skipped := range language.Skipped {
+				fmt.Fprintf(w, "           skipped %s: %s\n", skipped["path"], skipped["reason"])
+			}
+		}
+		if result.TransactionID != "" {
+			fmt.Fprintf(w, "Transaction: %s\n", result.TransactionID)
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Translation failed:", err)
+		return 1
+	}
+	for _, language := range result.Languages {
+		if language.Status == "failed" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runTranslateFieldCommand translates fields into existing translations, like POST /sanity_translate_field.
+func runTranslateFieldCommand(args []string) int {
+	var txx SanityFieldTranslator
+	var toSlugs, paths string
+	var asJSON bool
+
+	flags := flag.NewFlagSet("translate-field", flag.ContinueOnError)
+	flags.StringVar(&txx.FromLang, "from-lang", "", "language of the source document")
+	flags.StringVar(&txx.FromSlug, "from-slug", "", "slug of the source document")
+	flags.StringVar(&toSlugs, "to-slug", "", "comma separated slugs of the translated documents")
+	flags.StringVar(&paths, "path", "", "comma separated Sanity paths of the fields, e.g. text[0].intro")
+	flags.StringVar(&txx.Dataset, "dataset", "", "Sanity dataset, defaults to SANITY_DATASET")
+	flags.StringVar(&txx.GlossaryID, "glossary", "", "glossary to apply, defaults to the one stored for the language pair")
+	flags.StringVar(&txx.Options.Formality, "formality", "", "default, more, less, prefer_more or prefer_less")
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	txx.ToSlugs = splitList(toSlugs)
+	for _, path := range splitList(paths) {
+		txx.MappingFields = append(txx.MappingFields, MappingField{SanityPath: path})
+	}
+	if txx.FromLang == "" || txx.FromSlug == "" || len(txx.ToSlugs) == 0 || len(txx.MappingFields) == 0 {
+		fmt.Fprintln(os.Stderr, "-from-lang, -from-slug, -to-slug and -path are required")
+		return 2
+	}
+
+	translations, err := TranslateField(&txx)
+	printResult(asJSON, translations, func(w io.Writer) {
+		for _, translation := range translations {
+			fmt.Fprintf(w, "%s %s: %s\n", translation.DocumentID, translation.Path, translation.Translated)
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Translation failed:", err)
+		return 1
+	}
+	return 0
+}
+
+// runMetadataRepairCommand fixes the translation.metadata of one document or of every document matching a filter.
+func runMetadataRepairCommand(args []string) int {
+	var id, filter, dataset string
+	var dryRun, asJSON bool
+
+	flags := flag.NewFlagSet("metadata repair", flag.ContinueOnError)
+	flags.StringVar(&id, "id", "", "_id of the source document")
+	flags.StringVar(&filter, "filter", "", "GROQ filter selecting the source documents")
+	flags.StringVar(&dataset, "dataset", "", "Sanity dataset, defaults to SANITY_DATASET")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the changes without writing them")
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (id == "") == (filter == "") {
+		fmt.Fprintln(os.Stderr, "either -id or -filter is required")
+		return 2
+	}
+
+	var repairs []MetadataRepair
+	var err error
+	if id != "" {
+		var repair MetadataRepair
+		repair, err = RepairTranslationMetadata(dataset, id, dryRun)
+		if err != nil {
+			repair.Status = "failed"
+			repair.Error = err.Error()
+		}
+		repairs = []MetadataRepair{repair}
+	} else {
+		repairs, err = RepairTranslationMetadataFor(dataset, filter, dryRun)
+	}
+
+	printResult(asJSON, repairs, func(w io.Writer) {
+		for _, repair := range repairs {
+			fmt.Fprintf(w, "%-10s %s", repair.Status, repair.ID)
+			if len(repair.Added) > 0 {
+				fmt.Fprintf(w, " added %s", strings.Join(repair.Added, ","))
+			}
+			if len(repair.Removed) > 0 {
+				fmt.Fprintf(w, " removed %s", strings.Join(repair.Removed, ","))
+			}
+			fmt.Fprintf(w, " %s\n", repair.Error)
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Metadata repair failed:", err)
+		return 1
+	}
+	for _, repair := range repairs {
+		if repair.Status == "failed" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runBulkCommand runs a bulk translation from the command line and returns the exit code.
+func runBulkCommand(args []string) int {
+	var req BulkRequest
+	var toLangs, selectors string
+	var asJSON bool
+
+	flags := flag.NewFlagSet("bulk", flag.ContinueOnError)
+	flags.StringVar(&req.Filter, "filter", "", `GROQ filter selecting the documents, e.g. _type == "article"`)
+	flags.StringVar(&req.FromLang, "from-lang", "", "language of the source documents")
+	flags.StringVar(&toLangs, "to-langs", "", "comma separated languages to translate to")
+	flags.StringVar(&selectors, "select", "", "comma separated selectors of the fields to translate, empty to use the translation schema")
+	flags.StringVar(&req.Dataset, "dataset", "", "Sanity dataset, defaults to SANITY_DATASET")
+	flags.StringVar(&req.Draft, "draft", "", `write into drafts: "replace" or "patch"`)
+	flags.BoolVar(&req.Merge, "merge", false, "keep target-language edits")
+	flags.BoolVar(&req.Incremental, "incremental", false, "only translate the fields whose source changed")
+	flags.BoolVar(&req.PortableText, "portable-text", false, "translate Portable Text blocks as a whole")
+	flags.BoolVar(&req.Force, "force", false, "translate documents again even when already translated")
+	flags.IntVar(&req.PageSize, "page-size", DefaultBulkPageSize, "documents fetched per page")
+	flags.StringVar(&req.StateFile, "state", "", "file saving the progress, resumed when it exists")
+	flags.StringVar(&req.Cursor, "cursor", "", "start after this document _id, ignoring the progress saved in -state")
+	flags.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	req.ToLangs = splitList(toLangs)
+	for _, selector := range splitList(selectors) {
+		req.InputElements = append(req.InputElements, InputElement{Path: selector})
+	}
+
+	if err := ValidateBulkRequest(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := &BulkReport{Cursor: req.Cursor}
+	if req.StateFile != "" && req.Cursor == "" {
+		var err error
+		if report, err = LoadBulkReport(req.StateFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed loading bulk state:", err)
+			return 1
+		}
+		if report.Cursor != "" {
+			fmt.Fprintf(logOutput, "Resuming bulk translation after: %s\n", report.Cursor)
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	err := RunBulk(ctx, &req, report, func(outcome BulkOutcome) {
+		if req.StateFile == "" {
+			return
+		}
+		if err := report.Save(req.StateFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed saving bulk state:", err)
+		}
+	})
+
+	printResult(asJSON, report, func(w io.Writer) {
+		for _, outcome := range report.Documents {
+			fmt.Fprintf(w, "%-10s %s %s %s\n", outcome.Status, outcome.ID, outcome.Slug, outcome.Error)
+		}
+		fmt.Fprintf(w, "Processed %d documents: %d translated, %d partial, %d skipped, %d failed\n",
+			report.Processed, report.Translated, report.Partial, report.Skipped, report.Failed)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Bulk translation stopped:", err)
+		return 1
+	}
+	if report.Failed > 0 || report.Partial > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// captureCLIOutput collects what the commands print for the duration of the test.
+func captureCLIOutput(t *testing.T) *bytes.Buffer {
+	output := &bytes.Buffer{}
+	original := cliOutput
+	cliOutput = output
+	t.Cleanup(func() { cliOutput = original })
+	return output
+}
+
+func TestTranslateDocCommand(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		if strings.Contains(query, "translation.metadata") {
+			return `null`
+		}
+		return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title"}`
+	})
+	useFakeTranslator(t)
+	output := captureCLIOutput(t)
+
+	code := runTranslateDocCommand([]string{"-from-lang", "en", "-from-slug", "/en/doc", "-to-lang", "it,fr", "-select", "title", "-json"})
+	if code != 0 {
+		t.Fatalf("translate-doc exited with %d: %s", code, output.String())
+	}
+
+	result := gjson.Parse(output.String())
+	if result.Get("transactionId").String() != "tx-1" || result.Get("languages.#.slug").String() != `["/it/doc","/fr/doc"]` {
+		t.Errorf("unexpected output: %s", output.String())
+	}
+	if len(fake.mutations) != 1 || gjson.Get(fake.mutations[0], "mutations.1.createOrReplace.title").String() != "IT: Title" {
+		t.Errorf("unexpected mutations: %v", fake.mutations)
+	}
+}
+
+func TestCLIUsageErrors(t *testing.T) {
+	captureCLIOutput(t)
+
+	tests := []struct {
+		name string
+		run  func(args []string) int
+		args []string
+	}{
+		{name: "TranslateDocWithoutLanguage", run: runTranslateDocCommand, args: []string{"-from-lang", "en", "-from-slug", "/en/doc"}},
+		{name: "TranslateDocSlugsMismatch", run: runTranslateDocCommand, args: []string{"-from-lang", "en", "-from-slug", "/en/doc", "-to-lang", "it,fr", "-to-slug", "/it/doc"}},
+		{name: "TranslateFieldWithoutPath", run: runTranslateFieldCommand, args: []string{"-from-lang", "en", "-from-slug", "/en/doc", "-to-slug", "/it/doc"}},
+		{name: "BulkWithoutFilter", run: runBulkCommand, args: []string{"-from-lang", "en", "-to-langs", "it"}},
+		{name: "MetadataRepairWithoutTarget", run: runMetadataRepairCommand, args: []string{}},
+		{name: "UnknownFlag", run: runTranslateDocCommand, args: []string{"-nope"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := tt.run(tt.args); code != 2 {
+				t.Errorf("%s: exit code = %d, want 2", tt.name, code)
+			}
+		})
+	}
+}
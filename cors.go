@@ -14,12 +14,12 @@ func SetCORSConfig() gin.HandlerFunc {
 	env := os.Getenv("ENV")
 	switch env {
 	case "production":
-		fmt.Println("CORS: Production")
+		fmt.Fprintln(logOutput, "CORS: Production")
 		origins := os.Getenv("ALLOWED_ORIGINS")
 		originsSlice := strings.Split(origins, ",")
 		return cors.New(cors.Config{
 			AllowOrigins: originsSlice,
-			AllowMethods: []string{"POST", "OPTIONS", "GET"},
+			AllowMethods: []string{"POST", "OPTIONS", "GET", "PUT", "DELETE"},
 			AllowHeaders: []string{
 				"Content-Type",
 				"Content-Length",
@@ -38,17 +38,17 @@ func SetCORSConfig() gin.HandlerFunc {
 			MaxAge:           12 * time.Hour,
 		})
 	case "staging":
-		fmt.Println("CORS: Staging")
+		fmt.Fprintln(logOutput, "CORS: Staging")
 		// Ensure 'Content-Type' is allowed in staging
 		return cors.New(cors.Config{
 			AllowAllOrigins: true,
-			AllowMethods:    []string{"POST", "OPTIONS", "GET"},
+			AllowMethods:    []string{"POST", "OPTIONS", "GET", "PUT", "DELETE"},
 			AllowHeaders: []string{
 				"Content-Type", // explicitly allowing JSON Content-Type
 			},
 		})
 	default:
-		fmt.Println("CORS: Development")
+		fmt.Fprintln(logOutput, "CORS: Development")
 		// Allow all origins and headers in development for simplicity
 		return cors.Default()
 	}
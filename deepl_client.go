@@ -1,36 +1,236 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/tidwall/gjson"
 )
 
-func RunDeepl(text string, from_lang string, to_lang string) (string, error) {
-	params := url.Values{}
-	params.Add("text", text)
-	params.Add("source_lang", from_lang)
-	params.Add("target_lang", to_lang)
-	params.Add("preserve_formatting", "1")
-	body := strings.NewReader(params.Encode())
-
-	req, err := http.NewRequest(
-		"POST",
-		DeeplAPIURL,
-		body,
-	)
+const (
+	DeeplFreeAPIURL    = "https://api-free.deepl.com/v2"
+	DeeplProAPIURL     = "https://api.deepl.com/v2"
+	DeeplMaxBatchTexts = 50         // Maximum number of text parameters per request
+	DeeplMaxBatchBytes = 120 * 1024 // Encoded text parameters per request, leaving room for the others below the 128 KiB limit
+	DeeplMaxAttempts   = 3          // Attempts of a translate request rate limited by DeepL
+)
+
+// DeeplRequestInterval is the minimum time between two translate requests.
+const DeeplRequestInterval = 1 * time.Second
+
+// DeeplClient is the Translator implementation backed by the DeepL API.
+// It is safe for concurrent use; translate requests of all callers share its rate limit.
+type DeeplClient struct {
+	AuthKey  string
+	APIURL   string        // Base URL of the API (e.g. https://api.deepl.com/v2)
+	Interval time.Duration // Minimum time between two translate requests
+
+	mu   sync.Mutex
+	next time.Time // Earliest time of the next translate request
+}
+
+// NewDeeplClient returns a DeepL translator authenticated with the given key.
+// When apiURL is empty the endpoint is detected from the key.
+func NewDeeplClient(authKey string, apiURL string) *DeeplClient {
+	if apiURL == "" {
+		apiURL = DeeplAPIURLForKey(authKey)
+	}
+	return &DeeplClient{
+		AuthKey:  authKey,
+		APIURL:   strings.TrimSuffix(apiURL, "/"),
+		Interval: DeeplRequestInterval,
+	}
+}
+
+// DeeplAPIURLForKey returns the Free endpoint for free-tier keys (ending in ":fx")
+// and the Pro endpoint otherwise.
+func DeeplAPIURLForKey(authKey string) string {
+	if strings.HasSuffix(authKey, ":fx") {
+		return DeeplFreeAPIURL
+	}
+	return DeeplProAPIURL
+}
+
+// Name returns the identifier of the provider.
+func (d *DeeplClient) Name() string {
+	return "deepl"
+}
+
+// Translate translates text from from_lang to to_lang preserving formatting.
+func (d *DeeplClient) Translate(ctx context.Context, text string, from_lang string, to_lang string, opts TranslationOptions) (string, error) {
+	translations, err := d.TranslateBatch(ctx, []string{text}, from_lang, to_lang, opts)
 	if err != nil {
-		fmt.Println("Error creating Deepl request")
 		return "", err
 	}
+	return translations[0], nil
+}
+
+// TranslateBatch translates many texts, sending them to DeepL in size-bounded batches.
+// The returned slice has the same length and order as texts.
+func (d *DeeplClient) TranslateBatch(ctx context.Context, texts []string, from_lang string, to_lang string, opts TranslationOptions) ([]string, error) {
+	translations := make([]string, 0, len(texts))
+	for _, batch := range splitBatches(texts, DeeplMaxBatchTexts, DeeplMaxBatchBytes) {
+
+		params := url.Values{}
+		for _, text := range batch {
+			params.Add("text", text)
+		}
+		params.Add("source_lang", from_lang)
+		params.Add("target_lang", to_lang)
+		params.Add("preserve_formatting", "1")
+		if opts.GlossaryID != "" {
+			params.Add("glossary_id", opts.GlossaryID)
+		}
+		if opts.Formality != "" {
+			params.Add("formality", opts.Formality)
+		}
+		if opts.SplitSentences != "" {
+			params.Add("split_sentences", opts.SplitSentences)
+		}
+		if opts.Context != "" {
+			params.Add("context", opts.Context)
+		}
+		if opts.TagHandling != "" {
+			params.Add("tag_handling", opts.TagHandling)
+		}
+
+		bodyText, err := d.translateRequest(ctx, params)
+		if err != nil {
+			fmt.Fprintln(logOutput, "Error executing Deepl request")
+			return nil, err
+		}
+
+		results := gjson.Get(bodyText, "translations.#.text").Array()
+		if len(results) != len(batch) {
+			return nil, fmt.Errorf(
+				"deepl returned %d translations for %d texts",
+				len(results),
+				len(batch),
+			)
+		}
+		for j, result := range results {
+			translations = append(translations, preserveSpaces(batch[j], result.Str))
+		}
+	}
+	return translations, nil
+}
+
+// translateRequest sends a translate request when the rate limit allows it, retrying
+// with an increasing delay while DeepL answers 429
+func (d *DeeplClient) translateRequest(ctx context.Context, params url.Values) (string, error) {
+	for attempt := 1; ; attempt++ {
+		if err := d.wait(ctx); err != nil {
+			return "", err
+		}
+		bodyText, err := d.request(ctx, "POST", "/translate", params)
+		if !errors.Is(err, ErrRateLimited) || attempt == DeeplMaxAttempts {
+			return bodyText, err
+		}
+		delay := d.Interval << attempt
+		fmt.Fprintf(logOutput, "Deepl rate limit reached, retrying in %s (%d/%d)\n", delay, attempt, DeeplMaxAttempts)
+		d.delay(delay)
+	}
+}
+
+// wait blocks until the next translate request can be sent and reserves its slot
+func (d *DeeplClient) wait(ctx context.Context) error {
+	d.mu.Lock()
+	at := d.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	d.next = at.Add(d.Interval)
+	d.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay holds back the translate requests of every caller for at least delay
+func (d *DeeplClient) delay(delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if until := time.Now().Add(delay); d.next.Before(until) {
+		d.next = until
+	}
+}
+
+// SupportedLanguages returns the target languages available on DeepL.
+func (d *DeeplClient) SupportedLanguages() ([]Language, error) {
+	params := url.Values{}
+	params.Add("type", "target")
+
+	bodyText, err := d.request(context.Background(), "GET", "/languages", params)
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error fetching Deepl languages")
+		return nil, err
+	}
+
+	languages := []Language{}
+	for _, lang := range gjson.Parse(bodyText).Array() {
+		languages = append(
+			languages,
+			Language{
+				Code: lang.Get("language").String(),
+				Name: lang.Get("name").String(),
+			},
+		)
+	}
+	return languages, nil
+}
+
+// Usage returns the character consumption of the DeepL account.
+func (d *DeeplClient) Usage() (Usage, error) {
+	bodyText, err := d.request(context.Background(), "GET", "/usage", url.Values{})
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error fetching Deepl usage")
+		return Usage{}, err
+	}
+
+	return Usage{
+		CharacterCount: gjson.Get(bodyText, "character_count").Int(),
+		CharacterLimit: gjson.Get(bodyText, "character_limit").Int(),
+	}, nil
+}
+
+// request performs an authenticated call to the DeepL API and returns the response body.
+// It is aborted when ctx is cancelled.
+func (d *DeeplClient) request(ctx context.Context, method, path string, params url.Values) (string, error) {
+	fullURL := d.APIURL + path
+
+	var req *http.Request
+	var err error
+
+	if method == "GET" {
+		req, err = http.NewRequestWithContext(ctx, method, fullURL+"?"+params.Encode(), nil)
+	} else if method == "DELETE" {
+		req, err = http.NewRequestWithContext(ctx, method, fullURL, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, fullURL, strings.NewReader(params.Encode()))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error creating Deepl request: %w", err)
+	}
 	req.Header.Set(
 		"Authorization",
-		fmt.Sprintf(`DeepL-Auth-Key %s`, os.Getenv("DEEPL_TOKEN")),
+		fmt.Sprintf(`DeepL-Auth-Key %s`, d.AuthKey),
 	)
 	req.Header.Set(
 		"Content-Type",
@@ -38,25 +238,101 @@ func RunDeepl(text string, from_lang string, to_lang string) (string, error) {
 	)
 
 	resp, err := http.DefaultClient.Do(req)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "", &TranslationError{
+			Kind:     ErrTransient,
+			Provider: d.Name(),
+			Message:  err.Error(),
+		}
+	}
 	defer resp.Body.Close()
+
 	bodyText, err := ioutil.ReadAll(resp.Body)
-	if err != nil || resp.StatusCode != 200 {
-		fmt.Println("Error executing Deepl request")
-		fmt.Println("Status", resp.Status)
-		fmt.Println("Body", string(bodyText))
-		os.Exit(1)
+	if err != nil {
+		return "", &TranslationError{
+			Kind:     ErrTransient,
+			Provider: d.Name(),
+			Message:  err.Error(),
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", deeplError(resp.StatusCode, string(bodyText))
+	}
+
+	return string(bodyText), nil
+}
+
+// deeplError classifies a non-200 DeepL response.
+func deeplError(statusCode int, body string) *TranslationError {
+	message := gjson.Get(body, "message").String()
+	if message == "" {
+		message = strings.TrimSpace(body)
 	}
 
-	translated_text := gjson.Get(
-		string(bodyText),
-		"translations.0.text",
-	).Str
+	var kind error
+	switch {
+	case statusCode == 456:
+		kind = ErrQuotaExceeded
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		kind = ErrAuthFailed
+	case statusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case statusCode >= 500:
+		kind = ErrTransient
+	case statusCode == http.StatusBadRequest && strings.Contains(message, "_lang"):
+		kind = ErrUnsupportedLanguage
+	}
 
-	if text[0:1] == " " {
-		translated_text = " " + translated_text
+	return &TranslationError{
+		Kind:       kind,
+		Provider:   "deepl",
+		StatusCode: statusCode,
+		Message:    message,
+	}
+}
+
+// splitBatches groups texts in order so that no batch exceeds maxTexts items or maxBytes bytes
+// of form-urlencoded text parameters, which is how the request body sends them.
+// A single text bigger than maxBytes is sent on its own.
+func splitBatches(texts []string, maxTexts int, maxBytes int) [][]string {
+	batches := [][]string{}
+	current := []string{}
+	size := 0
+	for _, text := range texts {
+		encoded := encodedTextSize(text)
+		if len(current) > 0 && (len(current) == maxTexts || size+encoded > maxBytes) {
+			batches = append(batches, current)
+			current = []string{}
+			size = 0
+		}
+		current = append(current, text)
+		size += encoded
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// encodedTextSize returns the bytes text takes in the request body as a "text=...&" parameter.
+func encodedTextSize(text string) int {
+	return len(url.QueryEscape(text)) + len("text=&")
+}
+
+// preserveSpaces restores the leading and trailing space of the original text,
+// which DeepL drops but Portable Text spans rely on.
+func preserveSpaces(original string, translated string) string {
+	if original == "" {
+		return translated
+	}
+	if original[0:1] == " " && !strings.HasPrefix(translated, " ") {
+		translated = " " + translated
 	}
-	if text[len(text)-1:] == " " {
-		translated_text = translated_text + " "
+	if original[len(original)-1:] == " " && !strings.HasSuffix(translated, " ") {
+		translated = translated + " "
 	}
-	return translated_text, nil
+	return translated
 }
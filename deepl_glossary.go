@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// GlossaryManager is implemented by the providers supporting glossaries.
+type GlossaryManager interface {
+	CreateGlossary(name string, sourceLang string, targetLang string, entries string, format string) (Glossary, error)
+	ListGlossaries() ([]Glossary, error)
+	DeleteGlossary(id string) error
+}
+
+// Glossary is a set of term pairs applied when translating between two languages.
+type Glossary struct {
+	GlossaryID   string `json:"glossary_id"`
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	SourceLang   string `json:"source_lang"`
+	TargetLang   string `json:"target_lang"`
+	CreationTime string `json:"creation_time"`
+	EntryCount   int    `json:"entry_count"`
+}
+
+// CreateGlossary creates a DeepL glossary from CSV or TSV term pairs.
+func (d *DeeplClient) CreateGlossary(name string, sourceLang string, targetLang string, entries string, format string) (Glossary, error) {
+	if format != "csv" && format != "tsv" {
+		return Glossary{}, fmt.Errorf("unsupported glossary entries format: %s", format)
+	}
+
+	params := url.Values{}
+	params.Add("name", name)
+	params.Add("source_lang", sourceLang)
+	params.Add("target_lang", targetLang)
+	params.Add("entries", entries)
+	params.Add("entries_format", format)
+
+	bodyText, err := d.request(context.Background(), "POST", "/glossaries", params)
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error creating Deepl glossary")
+		return Glossary{}, err
+	}
+
+	var glossary Glossary
+	err = json.Unmarshal([]byte(bodyText), &glossary)
+	return glossary, err
+}
+
+// ListGlossaries returns all the glossaries of the DeepL account.
+func (d *DeeplClient) ListGlossaries() ([]Glossary, error) {
+	bodyText, err := d.request(context.Background(), "GET", "/glossaries", url.Values{})
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error listing Deepl glossaries")
+		return nil, err
+	}
+
+	glossaries := []Glossary{}
+	err = json.Unmarshal([]byte(gjson.Get(bodyText, "glossaries").Raw), &glossaries)
+	return glossaries, err
+}
+
+// DeleteGlossary deletes the DeepL glossary with the given id.
+func (d *DeeplClient) DeleteGlossary(id string) error {
+	_, err := d.request(context.Background(), "DELETE", "/glossaries/"+url.PathEscape(id), url.Values{})
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error deleting Deepl glossary:", id)
+		return err
+	}
+	return nil
+}
+
+// GlossaryDefaults stores the glossary used for a language pair when a request does not set one.
+// When Path is set, defaults are persisted there as JSON.
+type GlossaryDefaults struct {
+	Path     string
+	mu       sync.RWMutex
+	defaults map[string]string
+}
+
+// DefaultGlossaries is the store used by the HTTP handlers.
+var DefaultGlossaries = NewGlossaryDefaults(os.Getenv("GLOSSARY_DEFAULTS_FILE"))
+
+// NewGlossaryDefaults returns a store loaded from path, if it exists.
+func NewGlossaryDefaults(path string) *GlossaryDefaults {
+	g := &GlossaryDefaults{
+		Path:     path,
+		defaults: map[string]string{},
+	}
+	if path == "" {
+		return g
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return g
+	}
+	if err := json.Unmarshal(content, &g.defaults); err != nil {
+		fmt.Fprintln(logOutput, "Error loading glossary defaults:", err)
+	}
+	return g
+}
+
+// glossaryPairKey normalizes a language pair (e.g. "EN-IT").
+func glossaryPairKey(sourceLang string, targetLang string) string {
+	return strings.ToUpper(sourceLang) + "-" + strings.ToUpper(targetLang)
+}
+
+// Get returns the default glossary of the language pair, if any.
+func (g *GlossaryDefaults) Get(sourceLang string, targetLang string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.defaults[glossaryPairKey(sourceLang, targetLang)]
+}
+
+// All returns a copy of every stored default.
+func (g *GlossaryDefaults) All() map[string]string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	all := map[string]string{}
+	for pair, id := range g.defaults {
+		all[pair] = id
+	}
+	return all
+}
+
+// Set stores glossaryID as default for the language pair; an empty id removes it.
+func (g *GlossaryDefaults) Set(sourceLang string, targetLang string, glossaryID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if glossaryID == "" {
+		delete(g.defaults, glossaryPairKey(sourceLang, targetLang))
+	} else {
+		g.defaults[glossaryPairKey(sourceLang, targetLang)] = glossaryID
+	}
+	return g.save()
+}
+
+// Remove drops every default pointing at glossaryID, e.g. after deleting it.
+func (g *GlossaryDefaults) Remove(glossaryID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	removed := false
+	for pair, id := range g.defaults {
+		if id == glossaryID {
+			delete(g.defaults, pair)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return g.save()
+}
+
+// save writes the defaults to Path, if set. The caller holds mu.
+func (g *GlossaryDefaults) save() error {
+	if g.Path == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(g.defaults, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(g.Path, content, 0644)
+}
+
+// ResolveGlossaryID returns the glossary requested for targetLang in glossaries, the one
+// requested explicitly for the single target language, or the default of the language pair.
+func ResolveGlossaryID(glossaryID string, glossaries map[string]string, sourceLang string, targetLang string) string {
+	for lang, id := range glossaries {
+		if strings.EqualFold(lang, targetLang) && id != "" {
+			return id
+		}
+	}
+	if glossaryID != "" {
+		return glossaryID
+	}
+	return DefaultGlossaries.Get(sourceLang, targetLang)
+}
+
+// validateGlossaryID rejects an explicit glossary for several target languages:
+// a glossary only covers one language pair.
+func validateGlossaryID(glossaryID string, targetLangs int) error {
+	if glossaryID != "" && targetLangs > 1 {
+		return errors.New("invalid GlossaryID: a glossary covers one language pair, use Glossaries for several target languages")
+	}
+	return nil
+}
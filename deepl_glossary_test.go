@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeeplGlossaries(t *testing.T) {
+	var gotGlossaryID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/glossaries":
+			r.ParseForm()
+			if r.PostForm.Get("entries_format") != "csv" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"glossary_id":"g-1","name":"Brand","ready":true,"source_lang":"en","target_lang":"it","entry_count":1}`))
+		case r.Method == "GET" && r.URL.Path == "/glossaries":
+			w.Write([]byte(`{"glossaries":[{"glossary_id":"g-1","name":"Brand"}]}`))
+		case r.Method == "DELETE" && r.URL.Path == "/glossaries/g-1":
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/translate":
+			r.ParseForm()
+			gotGlossaryID = r.PostForm.Get("glossary_id")
+			w.Write([]byte(`{"translations":[{"text":"Ciao"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewDeeplClient("test-key", server.URL)
+
+	glossary, err := client.CreateGlossary("Brand", "en", "it", "Studio,Studio", "csv")
+	if err != nil {
+		t.Fatalf("CreateGlossary() returned an error: %v", err)
+	}
+	if glossary.GlossaryID != "g-1" || glossary.EntryCount != 1 {
+		t.Errorf("CreateGlossary() = %+v", glossary)
+	}
+
+	glossaries, err := client.ListGlossaries()
+	if err != nil {
+		t.Fatalf("ListGlossaries() returned an error: %v", err)
+	}
+	if len(glossaries) != 1 || glossaries[0].Name != "Brand" {
+		t.Errorf("ListGlossaries() = %+v", glossaries)
+	}
+
+	_, err = client.Translate(context.Background(), "Hello", "en", "it", TranslationOptions{GlossaryID: "g-1"})
+	if err != nil {
+		t.Fatalf("Translate() returned an error: %v", err)
+	}
+	if gotGlossaryID != "g-1" {
+		t.Errorf("Translate() sent glossary_id %q, want %q", gotGlossaryID, "g-1")
+	}
+
+	if err := client.DeleteGlossary("g-1"); err != nil {
+		t.Fatalf("DeleteGlossary() returned an error: %v", err)
+	}
+}
+
+func TestGlossaryDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossaries.json")
+
+	defaults := NewGlossaryDefaults(path)
+	if err := defaults.Set("en", "it", "g-1"); err != nil {
+		t.Fatalf("Set() returned an error: %v", err)
+	}
+
+	reloaded := NewGlossaryDefaults(path)
+	if got := reloaded.Get("EN", "IT"); got != "g-1" {
+		t.Errorf("Get() after reload = %q, want %q", got, "g-1")
+	}
+
+	if err := reloaded.Set("en", "it", ""); err != nil {
+		t.Fatalf("Set() returned an error: %v", err)
+	}
+	if got := reloaded.Get("en", "it"); got != "" {
+		t.Errorf("Get() after removal = %q, want empty", got)
+	}
+
+	// Deleting a glossary drops every pair using it
+	for pair, id := range map[string]string{"it": "g-2", "de": "g-2", "fr": "g-3"} {
+		if err := reloaded.Set("en", pair, id); err != nil {
+			t.Fatalf("Set() returned an error: %v", err)
+		}
+	}
+	if err := reloaded.Remove("g-2"); err != nil {
+		t.Fatalf("Remove() returned an error: %v", err)
+	}
+	reloaded = NewGlossaryDefaults(path)
+	if got := reloaded.All(); len(got) != 1 || got["EN-FR"] != "g-3" {
+		t.Errorf("All() after Remove() = %v, want only EN-FR", got)
+	}
+}
+
+func TestResolveGlossaryID(t *testing.T) {
+	previous := DefaultGlossaries
+	DefaultGlossaries = NewGlossaryDefaults("")
+	defer func() { DefaultGlossaries = previous }()
+	if err := DefaultGlossaries.Set("en", "de", "g-default-de"); err != nil {
+		t.Fatalf("Set() returned an error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		glossaryID string
+		glossaries map[string]string
+		targetLang string
+		want       string
+	}{
+		{name: "Default", targetLang: "de", want: "g-default-de"},
+		{name: "NoGlossary", targetLang: "fr", want: ""},
+		{name: "Explicit", glossaryID: "g-1", targetLang: "de", want: "g-1"},
+		{name: "PerLanguage", glossaries: map[string]string{"IT": "g-it"}, targetLang: "it", want: "g-it"},
+		{name: "PerLanguageFallsBackToDefault", glossaries: map[string]string{"it": "g-it"}, targetLang: "de", want: "g-default-de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveGlossaryID(tt.glossaryID, tt.glossaries, "en", tt.targetLang); got != tt.want {
+				t.Errorf("%s: ResolveGlossaryID() = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job statuses.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	DefaultJobWorkers   = 2
+	DefaultJobQueueSize = 100
+	JobRetention        = time.Hour // How long finished jobs stay available
+)
+
+// ErrQueueFull is returned by Enqueue when no more jobs can be queued.
+var ErrQueueFull = errors.New("job queue is full")
+
+// TranslationHooks are called while a document is translated. Every hook is optional.
+type TranslationHooks struct {
+	FieldFound      func(lang string, field SanityField)                // A field was selected, SkipReason tells if it is skipped
+	FieldsPending   func(count int)                                     // count fields are about to be sent to the provider
+	FieldTranslated func(lang string, field SanityField)                // The provider returned the translation of a field
+	MutationSent    func(attempt int, result MutationResult, err error) // A transaction was sent to Sanity
+	MetadataPlanned func(mutation Mutation)                             // The translation.metadata change was planned
+}
+
+func (h TranslationHooks) fieldFound(lang string, field SanityField) {
+	if h.FieldFound != nil {
+		h.FieldFound(lang, field)
+	}
+}
+
+func (h TranslationHooks) fieldsPending(count int) {
+	if h.FieldsPending != nil {
+		h.FieldsPending(count)
+	}
+}
+
+func (h TranslationHooks) fieldTranslated(lang string, field SanityField) {
+	if h.FieldTranslated != nil {
+		h.FieldTranslated(lang, field)
+	}
+}
+
+func (h TranslationHooks) mutationSent(attempt int, result MutationResult, err error) {
+	if h.MutationSent != nil {
+		h.MutationSent(attempt, result, err)
+	}
+}
+
+func (h TranslationHooks) metadataPlanned(mutation Mutation) {
+	if h.MetadataPlanned != nil {
+		h.MetadataPlanned(mutation)
+	}
+}
+
+// JobEvent is a step of a job, streamed by GET /jobs/:id/events.
+type JobEvent struct {
+	ID   int         `json:"id"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// JobFunc runs the work of a job, stopping when ctx is cancelled.
+type JobFunc func(ctx context.Context, job *Job) (interface{}, error)
+
+// Job is a translation running in the background.
+type Job struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"`
+	FieldsDone  int         `json:"fieldsDone"`
+	FieldsTotal int         `json:"fieldsTotal"`
+	Error       string      `json:"error,omitempty"`
+	Result      interface{} `json:"result,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+
+	mu      sync.Mutex
+	events  []JobEvent
+	changed chan struct{} // Closed and replaced whenever the job changes
+	run     JobFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// Snapshot returns a copy of the job safe to serialize.
+func (j *Job) Snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Job{
+		ID:          j.ID,
+		Description: j.Description,
+		Status:      j.Status,
+		FieldsDone:  j.FieldsDone,
+		FieldsTotal: j.FieldsTotal,
+		Error:       j.Error,
+		Result:      j.Result,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+	}
+}
+
+// AddProgress records that done more fields were translated, out of total more fields to translate.
+func (j *Job) AddProgress(done int, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.FieldsDone += done
+	j.FieldsTotal += total
+	j.UpdatedAt = time.Now()
+}
+
+// SetResult publishes the partial result of a running job.
+func (j *Job) SetResult(result interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Result = result
+	j.UpdatedAt = time.Now()
+}
+
+// Emit appends an event of type eventType to the job and wakes up its listeners.
+func (j *Job) Emit(eventType string, data interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.emit(eventType, data)
+}
+
+// emit must be called with j.mu held
+func (j *Job) emit(eventType string, data interface{}) {
+	j.events = append(j.events, JobEvent{ID: len(j.events) + 1, Type: eventType, Data: data})
+	j.UpdatedAt = time.Now()
+	if j.changed != nil {
+		close(j.changed)
+	}
+	j.changed = make(chan struct{})
+}
+
+// EventsAfter returns the events following the one with id lastID, a channel
+// closed on the next change, and whether the job is over.
+func (j *Job) EventsAfter(lastID int) ([]JobEvent, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.changed == nil {
+		j.changed = make(chan struct{})
+	}
+	if lastID < 0 || lastID > len(j.events) {
+		lastID = 0
+	}
+	events := append([]JobEvent{}, j.events[lastID:]...)
+	return events, j.changed, j.Status != JobQueued && j.Status != JobRunning
+}
+
+// TranslationHooks returns hooks recording the progress of a document translation as job events.
+func (j *Job) TranslationHooks() TranslationHooks {
+	return TranslationHooks{
+		FieldFound: func(lang string, field SanityField) {
+			j.Emit("field_found", gin.H{"lang": lang, "path": field.Path, "skipReason": field.SkipReason})
+		},
+		FieldsPending: func(count int) {
+			j.AddProgress(0, count)
+		},
+		FieldTranslated: func(lang string, field SanityField) {
+			j.AddProgress(1, 0)
+			j.Emit("field_translated", gin.H{"lang": lang, "path": field.Path, "translated": field.TranslatedContent})
+		},
+		MutationSent: func(attempt int, result MutationResult, err error) {
+			data := gin.H{"attempt": attempt, "transactionId": result.TransactionID}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			j.Emit("mutation", data)
+		},
+		MetadataPlanned: func(mutation Mutation) {
+			j.Emit("metadata", mutation)
+		},
+	}
+}
+
+// Finished reports whether the job is no longer queued or running.
+func (j *Job) Finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Status != JobQueued && j.Status != JobRunning
+}
+
+// setStatus moves the job to status, unless it was cancelled already
+func (j *Job) setStatus(status string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Status == JobCancelled {
+		return false
+	}
+	j.Status = status
+	j.emit(status, nil)
+	return true
+}
+
+// finish stores the outcome of the job. A job cancelled while running that
+// completed anyway, e.g. after writing to Sanity, succeeded.
+func (j *Job) finish(result interface{}, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Result = result
+	j.UpdatedAt = time.Now()
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.Status = JobCancelled
+	case err != nil:
+		j.Status = JobFailed
+		j.Error = err.Error()
+	default:
+		j.Status = JobSucceeded
+	}
+	j.emit(j.Status, gin.H{"error": j.Error, "result": j.Result})
+}
+
+// JobQueue runs jobs on a fixed number of workers.
+type JobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending chan *Job
+}
+
+// ActiveJobs is the queue used by the HTTP handlers, sized from TRANSLATION_WORKERS and TRANSLATION_QUEUE_SIZE.
+var ActiveJobs = NewJobQueue(
+	envInt("TRANSLATION_WORKERS", DefaultJobWorkers),
+	envInt("TRANSLATION_QUEUE_SIZE", DefaultJobQueueSize),
+)
+
+// envInt reads a positive integer from the environment
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// NewJobQueue starts workers goroutines serving up to queueSize pending jobs.
+func NewJobQueue(workers int, queueSize int) *JobQueue {
+	q := &JobQueue{
+		jobs:    map[string]*Job{},
+		pending: make(chan *Job, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+func (q *JobQueue) work() {
+	for job := range q.pending {
+		if !job.setStatus(JobRunning) {
+			continue
+		}
+		fmt.Fprintf(logOutput, "Job %s started: %s\n", job.ID, job.Description)
+		result, err := job.run(job.ctx, job)
+		job.finish(result, err)
+		job.cancel()
+		fmt.Fprintf(logOutput, "Job %s %s\n", job.ID, job.Snapshot().Status)
+	}
+}
+
+// Enqueue queues run as a new job.
+func (q *JobQueue) Enqueue(description string, run JobFunc) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	job := &Job{
+		ID:          id,
+		Description: description,
+		Status:      JobQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		run:         run,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(now)
+	select {
+	case q.pending <- job:
+	default:
+		cancel()
+		return nil, ErrQueueFull
+	}
+	q.jobs[id] = job
+	return job, nil
+}
+
+// Get returns the job with id.
+func (q *JobQueue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	return job, ok
+}
+
+// Cancel stops the job with id. It returns false when the job had already finished.
+// A queued job is cancelled right away; a running job is cancelled when its
+// function returns context.Canceled.
+func (q *JobQueue) Cancel(id string) (*Job, bool) {
+	job, ok := q.Get(id)
+	if !ok || job.Finished() {
+		return job, false
+	}
+	job.mu.Lock()
+	if job.Status == JobQueued {
+		job.Status = JobCancelled
+		job.UpdatedAt = time.Now()
+		job.emit(JobCancelled, nil)
+	}
+	job.mu.Unlock()
+	job.cancel()
+	return job, true
+}
+
+// prune drops the jobs finished more than JobRetention ago
+func (q *JobQueue) prune(now time.Time) {
+	for id, job := range q.jobs {
+		if job.Finished() && now.Sub(job.Snapshot().UpdatedAt) > JobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random hexadecimal job id
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
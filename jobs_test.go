@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// waitForJob polls job until it finishes or the test times out.
+func waitForJob(t *testing.T, job *Job) Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for !job.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not finish, status %s", job.ID, job.Snapshot().Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return job.Snapshot()
+}
+
+func TestJobQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		run        JobFunc
+		cancel     bool
+		wantStatus string
+		wantError  string
+	}{
+		{
+			name: "Succeeded",
+			run: func(ctx context.Context, job *Job) (interface{}, error) {
+				job.AddProgress(0, 3)
+				job.AddProgress(2, 0)
+				job.AddProgress(1, 0)
+				return "done", nil
+			},
+			wantStatus: JobSucceeded,
+		},
+		{
+			name: "Failed",
+			run: func(ctx context.Context, job *Job) (interface{}, error) {
+				return nil, errors.New("boom")
+			},
+			wantStatus: JobFailed,
+			wantError:  "boom",
+		},
+		{
+			name: "Cancelled",
+			run: func(ctx context.Context, job *Job) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			cancel:     true,
+			wantStatus: JobCancelled,
+		},
+		{
+			name: "CompletedAfterCancel",
+			run: func(ctx context.Context, job *Job) (interface{}, error) {
+				<-ctx.Done()
+				return "written", nil
+			},
+			cancel:     true,
+			wantStatus: JobSucceeded,
+		},
+	}
+
+	queue := NewJobQueue(1, 10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job, err := queue.Enqueue(tt.name, tt.run)
+			if err != nil {
+				t.Fatalf("%s: Enqueue() returned an error: %v", tt.name, err)
+			}
+			if tt.cancel {
+				for job.Snapshot().Status == JobQueued {
+					time.Sleep(time.Millisecond)
+				}
+				if _, cancelled := queue.Cancel(job.ID); !cancelled {
+					t.Fatalf("%s: Cancel() did not cancel the job", tt.name)
+				}
+			}
+
+			snapshot := waitForJob(t, job)
+			if snapshot.Status != tt.wantStatus || snapshot.Error != tt.wantError {
+				t.Errorf("%s: job = %s %q, want %s %q", tt.name, snapshot.Status, snapshot.Error, tt.wantStatus, tt.wantError)
+			}
+			if _, cancelled := queue.Cancel(job.ID); cancelled {
+				t.Errorf("%s: Cancel() of a finished job should fail", tt.name)
+			}
+		})
+	}
+}
+
+func TestJobQueueFull(t *testing.T) {
+	queue := NewJobQueue(0, 1)
+	wait := func(ctx context.Context, job *Job) (interface{}, error) { return nil, nil }
+
+	if _, err := queue.Enqueue("first", wait); err != nil {
+		t.Fatalf("Enqueue() returned an error: %v", err)
+	}
+	if _, err := queue.Enqueue("second", wait); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Enqueue() = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestSanityTranslateDocumentAsyncTargets(t *testing.T) {
+	useFakeSanity(t, func(query string) string {
+		if strings.Contains(query, "translation.metadata") {
+			return `null`
+		}
+		return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title"}`
+	})
+	w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "InputElements": ["title"], "Async": true,
+		"Targets": [{"Lang": "it", "Slug": "/it/doc"}, {"Lang": "fr", "Slug": "/fr/doc"}]}`)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %v, got %v: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	job, ok := ActiveJobs.Get(gjson.Get(w.Body.String(), "jobId").String())
+	if !ok {
+		t.Fatalf("job not found: %s", w.Body.String())
+	}
+	waitForJob(t, job)
+	if want := "Document translation /en/doc to /it/doc, /fr/doc"; job.Description != want {
+		t.Errorf("Description = %q, want %q", job.Description, want)
+	}
+}
+
+func TestSanityTranslateDocumentAsync(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		if strings.Contains(query, "translation.metadata") {
+			return `null`
+		}
+		return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title", "intro": "Intro"}`
+	})
+	w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc", "InputElements": ["title", "intro"], "Async": true}`)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %v, got %v: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	id := gjson.Get(w.Body.String(), "jobId").String()
+	job, ok := ActiveJobs.Get(id)
+	if !ok {
+		t.Fatalf("job %q not found", id)
+	}
+	waitForJob(t, job)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jobs/:id", FetchJob)
+	router.DELETE("/jobs/:id", CancelJob)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+id, nil))
+	response := gjson.Parse(w.Body.String())
+	if w.Code != http.StatusOK || response.Get("status").String() != JobSucceeded {
+		t.Fatalf("GET /jobs/%s = %v %s", id, w.Code, w.Body.String())
+	}
+	if response.Get("fieldsDone").Int() != 2 || response.Get("fieldsTotal").Int() != 2 {
+		t.Errorf("progress = %s/%s, want 2/2", response.Get("fieldsDone").Raw, response.Get("fieldsTotal").Raw)
+	}
+	if response.Get("result.transactionId").String() != "tx-1" || len(fake.mutations) != 1 {
+		t.Errorf("unexpected result %s after %d mutate requests", response.Get("result").Raw, len(fake.mutations))
+	}
+
+	events, _, _ := job.EventsAfter(0)
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Type]++
+	}
+	want := map[string]int{JobRunning: 1, "field_found": 2, "field_translated": 2, "metadata": 1, "mutation": 1, JobSucceeded: 1}
+	for eventType, n := range want {
+		if counts[eventType] != n {
+			t.Errorf("%d %s events, want %d: %v", counts[eventType], eventType, n, counts)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/jobs/"+id, nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("DELETE of a finished job = %v, want %v", w.Code, http.StatusConflict)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown job = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestStreamQueuedJobEvents(t *testing.T) {
+	// No workers: the job stays queued and has no event yet
+	queue := NewJobQueue(0, 1)
+	job, err := queue.Enqueue("queued", func(ctx context.Context, job *Job) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Enqueue() returned an error: %v", err)
+	}
+	previous := ActiveJobs
+	ActiveJobs = queue
+	defer func() { ActiveJobs = previous }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jobs/:id/events", StreamJobEvents)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Fatalf("GET /jobs/%s/events returned an error: %v", job.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control = %q, want no-cache", got)
+	}
+	queue.Cancel(job.ID)
+}
+
+func TestStreamJobEvents(t *testing.T) {
+	release := make(chan struct{})
+	job, err := ActiveJobs.Enqueue("stream", func(ctx context.Context, job *Job) (interface{}, error) {
+		job.Emit("step", gin.H{"n": 1})
+		<-release
+		job.Emit("step", gin.H{"n": 2})
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatalf("Enqueue() returned an error: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jobs/:id/events", StreamJobEvents)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Fatalf("GET /jobs/%s/events returned an error: %v", job.ID, err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	// Events arrive while the job runs, the stream ends when it finishes
+	var types []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "event:") {
+			continue
+		}
+		types = append(types, strings.TrimPrefix(line, "event:"))
+		if len(types) == 2 {
+			close(release)
+		}
+	}
+	want := []string{JobRunning, "step", "step", JobSucceeded}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+
+	// Reconnecting clients only get the events after Last-Event-ID
+	req, _ := http.NewRequest("GET", server.URL+"/jobs/"+job.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "3")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /jobs/%s/events returned an error: %v", job.ID, err)
+	}
+	defer resp.Body.Close()
+	replay, _ := io.ReadAll(resp.Body)
+	if body := string(replay); strings.Count(body, "event:") != 1 || !strings.Contains(body, "event:"+JobSucceeded) {
+		t.Errorf("events after Last-Event-ID 3 = %q, want only %s", body, JobSucceeded)
+	}
+}
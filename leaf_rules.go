@@ -0,0 +1,67 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	urlPattern      = regexp.MustCompile(`^((https?|ftp)://|www\.|mailto:|tel:)\S+$`)
+	emailPattern    = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+	hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	slugPattern     = regexp.MustCompile(`^/?[a-z0-9]+([-/][a-z0-9]+)*/?$`)
+)
+
+// LeafRules overrides the rules skipping strings that must not be translated.
+// By default every rule is applied.
+type LeafRules struct {
+	TranslateSystemFields bool // Translate underscore-prefixed fields (_key, _ref, _type...)
+	TranslateURLs         bool
+	TranslateEmails       bool
+	TranslateHexColors    bool
+	TranslateSlugs        bool
+}
+
+// SkipReason returns why the string value at path must not be translated,
+// or an empty string when it can be translated.
+func (r LeafRules) SkipReason(path string, value string) string {
+	if !r.TranslateSystemFields && isSystemPath(path) {
+		return "system field"
+	}
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case !r.TranslateURLs && urlPattern.MatchString(trimmed):
+		return "url"
+	case !r.TranslateEmails && emailPattern.MatchString(trimmed):
+		return "email"
+	case !r.TranslateHexColors && hexColorPattern.MatchString(trimmed):
+		return "hex color"
+	case !r.TranslateSlugs && isSlug(path, trimmed):
+		return "slug"
+	}
+	return ""
+}
+
+// isSlug reports whether value is a slug. Hyphenated words such as "sign-up" look like
+// slugs too, so only values starting with "/" or stored under a slug field are considered.
+func isSlug(path string, value string) bool {
+	if !slugPattern.MatchString(value) {
+		return false
+	}
+	if strings.HasPrefix(value, "/") {
+		return true
+	}
+	segments := strings.Split(path, ".")
+	last := segments[len(segments)-1]
+	return last == "slug" || last == "current"
+}
+
+// isSystemPath reports whether any segment of the dotted path is an underscore-prefixed system field.
+func isSystemPath(path string) bool {
+	for _, segment := range strings.Split(path, ".") {
+		if strings.HasPrefix(segment, "_") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestLeafRulesSkipReason(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules LeafRules
+		path  string
+		value string
+		want  string
+	}{
+		{name: "Text", path: "title", value: "This is a test title", want: ""},
+		{name: "SystemField", path: "sections.0._type", value: "hero", want: "system field"},
+		{name: "NestedSystemField", path: "image.asset._ref", value: "image-abc", want: "system field"},
+		{name: "SystemFieldOverride", rules: LeafRules{TranslateSystemFields: true}, path: "_note", value: "Hello there", want: ""},
+		{name: "URL", path: "cta.href", value: "https://example.com/page", want: "url"},
+		{name: "URLOverride", rules: LeafRules{TranslateURLs: true}, path: "cta.href", value: "https://example.com/page", want: ""},
+		{name: "Email", path: "contact", value: "info@example.com", want: "email"},
+		{name: "HexColor", path: "color", value: "#FF00aa", want: "hex color"},
+		{name: "Slug", path: "slug.current", value: "/en/this-is-a-test-slug", want: "slug"},
+		{name: "SlugField", path: "slug.current", value: "this-is-a-test-slug", want: "slug"},
+		{name: "SlugPath", path: "cta.link", value: "/en/pricing", want: "slug"},
+		{name: "SlugOverride", rules: LeafRules{TranslateSlugs: true}, path: "slug.current", value: "/en/this-is-a-test", want: ""},
+		{name: "HyphenatedWord", path: "cta.label", value: "sign-up", want: ""},
+		{name: "HyphenatedWords", path: "footer.title", value: "follow-us", want: ""},
+		{name: "HyphenatedCompound", path: "category", value: "e-commerce", want: ""},
+		{name: "YearRange", path: "season", value: "2023-2024", want: ""},
+		{name: "SingleWord", path: "label", value: "hello", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.SkipReason(tt.path, tt.value); got != tt.want {
+				t.Errorf("%s: SkipReason() = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTranslationOnlyStrings(t *testing.T) {
+	txx := SanityDocumentTranslator{
+		InputElements: []InputElement{{Path: "**"}},
+	}
+	document := map[string]interface{}{
+		"title":     "This is a test title",
+		"count":     float64(3),
+		"published": true,
+		"_type":     "test",
+		"image":     map[string]interface{}{"asset": map[string]interface{}{"_ref": "image-abc"}},
+		"href":      "https://example.com",
+	}
+
+	if err := ExecuteTranslation(&txx, document, ""); err != nil {
+		t.Fatalf("ExecuteTranslation() returned an error: %v", err)
+	}
+	if len(txx.Fields) != 1 || txx.Fields[0].Path != "title" {
+		t.Errorf("ExecuteTranslation() fields = %+v, want only title", txx.Fields)
+	}
+}
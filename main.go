@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/gin-gonic/gin"
@@ -10,18 +11,45 @@ import (
 )
 
 var (
-	ProjectID   = os.Getenv("SANITY_PROJECT_ID")
-	Version     = os.Getenv("SANITY_VERSION")
-	Token       = os.Getenv("SANITY_TOKEN")
-	DeeplAPIURL = "https://api-free.deepl.com/v2/translate"
-	BaseAPIURL  = "https://%s.api.sanity.io/%s/data"
+	TranslationProvider   = os.Getenv("TRANSLATION_PROVIDER")
+	TranslationSchemaFile = os.Getenv("TRANSLATION_SCHEMA_FILE")
 )
 
+// logOutput receives the progress and error messages of the service.
+var logOutput io.Writer = os.Stdout
+
 func main() {
 
 	gin.SetMode(gin.ReleaseMode)
 
+	os.Exit(RunCLI(os.Args[1:]))
+}
+
+// setup configures the translation provider and schema from the environment
+func setup() error {
+	translator, err := NewTranslator(TranslationProvider)
+	if err != nil {
+		fmt.Fprintln(logOutput, "Failed setting up translation provider:", err)
+		return err
+	}
+	ActiveTranslator = translator
+	fmt.Fprintf(logOutput, "Translation provider: %s\n", ActiveTranslator.Name())
+
+	if TranslationSchemaFile != "" {
+		ActiveSchema, err = LoadTranslationSchema(TranslationSchemaFile)
+		if err != nil {
+			fmt.Fprintln(logOutput, "Failed loading translation schema:", err)
+			return err
+		}
+		fmt.Fprintf(logOutput, "Translation schema: %v\n", ActiveSchema.DocumentTypes())
+	}
+	return nil
+}
+
+// NewRouter returns the HTTP server with every endpoint.
+func NewRouter() *gin.Engine {
 	router := gin.New()
+	router.Use(gin.Recovery())
 
 	corsConfig := SetCORSConfig()
 	if corsConfig != nil {
@@ -30,12 +58,18 @@ func main() {
 
 	router.POST("/sanity_translate_document", SanityTranslateDocument)
 	router.POST("/sanity_translate_field", SanityTranslateField)
+	router.POST("/sanity_bulk_translate", SanityBulkTranslate)
 
-	router.GET("/health", FetchHealth)
+	router.POST("/glossaries", CreateGlossary)
+	router.GET("/glossaries", FetchGlossaries)
+	router.DELETE("/glossaries/:id", RemoveGlossary)
+	router.PUT("/glossaries/defaults", SetDefaultGlossary)
 
-	fmt.Println("Starting Sanity Translation Service")
-	fmt.Println("")
+	router.GET("/jobs/:id", FetchJob)
+	router.GET("/jobs/:id/events", StreamJobEvents)
+	router.DELETE("/jobs/:id", CancelJob)
 
-	router.Run(":8001")
+	router.GET("/health", FetchHealth)
 
+	return router
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// MetadataRepair is the outcome of RepairTranslationMetadata for one source document.
+type MetadataRepair struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"` // "ok", "repaired" or "failed"
+	Added    []string  `json:"added,omitempty"`
+	Removed  []string  `json:"removed,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Mutation *Mutation `json:"mutation,omitempty"`
+}
+
+// metadataRepairQuery fetches a source document, the translations named after it
+// (<id>_<lang>, published or draft) and its translation.metadata
+const metadataRepairQuery = `{
+	"source": *[_id == $id][0]{_id, _type, language},
+	"translations": *[(_id in path($pattern) || _id in path("drafts." + $pattern)) && _type != "translation.metadata" && defined(language)]{_id, language},
+	"metadata": coalesce(*[_type == "translation.metadata" && references($id)][0], *[_id == $baseId][0])
+}`
+
+// RepairTranslationMetadata makes the translation.metadata of the source document id
+// reference every existing translation: references to deleted documents are removed,
+// and the translations written by this service (<id>_<lang>) missing from it are added.
+// With dryRun the mutation is only planned. When the metadata changes before the
+// repair is written, it is planned again.
+func RepairTranslationMetadata(dataset string, id string, dryRun bool) (MetadataRepair, error) {
+	sanity := sanityFor(dataset).WithoutCDN()
+	for attempt := 1; ; attempt++ {
+		repair, err := planMetadataRepair(sanity, id)
+		if err != nil || repair.Mutation == nil || dryRun {
+			return repair, err
+		}
+		_, err = sanity.Mutate(*repair.Mutation)
+		if err == nil {
+			fmt.Fprintf(logOutput, "Repaired translation metadata of %s: added %v, removed %v\n", id, repair.Added, repair.Removed)
+			return repair, nil
+		}
+		if !isRevisionConflict(err) || attempt == metadataMaxAttempts {
+			return repair, err
+		}
+		fmt.Fprintf(logOutput, "⚠️ Translation metadata of %s changed concurrently, retrying (%d/%d)\n", id, attempt, metadataMaxAttempts)
+	}
+}
+
+// planMetadataRepair returns the repair of the translation.metadata of id, with the
+// mutation writing it when something changes
+func planMetadataRepair(sanity *SanityClient, id string) (MetadataRepair, error) {
+	repair := MetadataRepair{ID: id, Status: "ok"}
+
+	response, err := sanity.Query(metadataRepairQuery, map[string]interface{}{"id": id, "pattern": id + "_*", "baseId": translationMetadataID(id)})
+	if err != nil {
+		return repair, err
+	}
+	result := gjson.Get(response, "result")
+	source := result.Get("source")
+	if !source.IsObject() {
+		return repair, fmt.Errorf("source document %s not found", id)
+	}
+	sourceLang := source.Get("language").String()
+	if sourceLang == "" {
+		return repair, fmt.Errorf("source document %s has no language", id)
+	}
+	metadata := result.Get("metadata")
+	if metadata.IsObject() {
+		if err := checkBaseMetadata(metadata, id); err != nil {
+			return repair, err
+		}
+	}
+
+	// Check which documents referenced by the metadata still exist
+	referenced := []string{}
+	for _, ref := range metadata.Get("translations.#.value._ref").Array() {
+		referenced = append(referenced, ref.String(), draftsPrefix+ref.String())
+	}
+	existing := map[string]bool{}
+	if len(referenced) > 0 {
+		response, err := sanity.Query(`*[_id in $ids]._id`, map[string]interface{}{"ids": referenced})
+		if err != nil {
+			return repair, err
+		}
+		for _, documentID := range gjson.Get(response, "result").Array() {
+			existing[publishedID(documentID.String())] = true
+		}
+	}
+
+	txx := &SanityDocumentTranslator{Id: id, FromLang: sourceLang, Before: source.Raw}
+	translations := []interface{}{translationReference(sourceLang, id)}
+	languages := map[string]bool{sourceLang: true}
+	for _, translation := range metadata.Get("translations").Array() {
+		lang := translation.Get("_key").String()
+		ref := translation.Get("value._ref").String()
+		if lang == sourceLang || languages[lang] {
+			continue
+		}
+		if !existing[ref] {
+			repair.Removed = append(repair.Removed, lang)
+			continue
+		}
+		languages[lang] = true
+		translations = append(translations, translation.Value())
+	}
+
+	// Prefer the published translation when a draft exists too
+	found := map[string]string{}
+	for _, translation := range result.Get("translations").Array() {
+		lang := translation.Get("language").String()
+		documentID := translation.Get("_id").String()
+		if current, ok := found[lang]; !ok || strings.HasPrefix(current, draftsPrefix) {
+			found[lang] = documentID
+		}
+	}
+	langs := make([]string, 0, len(found))
+	for lang := range found {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		if languages[lang] {
+			continue
+		}
+		target := *txx
+		target.ToLang = lang
+		target.After = fmt.Sprintf(`{"_id": %q}`, found[lang])
+		translations = append(translations, targetTranslationReference(&target))
+		languages[lang] = true
+		repair.Added = append(repair.Added, lang)
+	}
+
+	if metadata.IsObject() && referencesDocument(metadata, id) && len(repair.Added) == 0 && len(repair.Removed) == 0 {
+		return repair, nil
+	}
+
+	var mutation Mutation
+	if !metadata.IsObject() {
+		if len(translations) == 1 {
+			return repair, nil
+		}
+		fresh := buildTranslationMetadata([]*SanityDocumentTranslator{txx})
+		fresh["translations"] = translations
+		mutation = Mutation{Create: fresh}
+	} else {
+		mutation = Mutation{Patch: &Patch{
+			ID:           metadata.Get("_id").String(),
+			IfRevisionID: metadata.Get("_rev").String(),
+			Set:          map[string]interface{}{"translations": translations},
+		}}
+	}
+	repair.Status = "repaired"
+	repair.Mutation = &mutation
+	return repair, nil
+}
+
+// RepairTranslationMetadataFor repairs the translation.metadata of every
+// published document matching the GROQ filter.
+func RepairTranslationMetadataFor(dataset string, filter string, dryRun bool) ([]MetadataRepair, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, errors.New("invalid filter: it must not be empty")
+	}
+	response, err := sanityFor(dataset).Query(
+		`*[(`+filter+`) && !(_id in path("drafts.**")) && _type != "translation.metadata"] | order(_id asc)._id`,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	repairs := []MetadataRepair{}
+	for _, id := range gjson.Get(response, "result").Array() {
+		repair, err := RepairTranslationMetadata(dataset, id.String(), dryRun)
+		if err != nil {
+			repair.Status = "failed"
+			repair.Error = err.Error()
+		}
+		repairs = append(repairs, repair)
+	}
+	return repairs, nil
+}
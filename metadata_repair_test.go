@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRepairTranslationMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		translations string // Documents named <id>_<lang>
+		metadata     string
+		existing     string // Referenced documents that exist
+		wantStatus   string
+		wantAdded    string
+		wantRemoved  string
+		wantErr      bool
+		check        func(t *testing.T, mutation gjson.Result)
+	}{
+		{
+			name:         "UpToDate",
+			translations: `[{"_id": "doc_it", "language": "it"}]`,
+			metadata:     existingMetadata,
+			existing:     `["doc", "old_it"]`,
+			wantStatus:   "ok",
+		},
+		{
+			name:         "CreateMissingMetadata",
+			translations: `[{"_id": "doc_it", "language": "it"}, {"_id": "drafts.doc_fr", "language": "fr"}]`,
+			metadata:     `null`,
+			wantStatus:   "repaired",
+			wantAdded:    "fr,it",
+			check: func(t *testing.T, mutation gjson.Result) {
+				create := mutation.Get("create")
+				if create.Get("_id").String() != "doc_base" || create.Get("translations.#._key").String() != `["en","fr","it"]` {
+					t.Errorf("unexpected metadata: %s", create.Raw)
+				}
+				if !create.Get("translations.1.value._weak").Bool() || create.Get("translations.1.value._ref").String() != "doc_fr" {
+					t.Errorf("draft translation should be referenced weakly: %s", create.Get("translations.1").Raw)
+				}
+			},
+		},
+		{
+			name:         "RemoveDeletedAndAddMissing",
+			translations: `[{"_id": "doc_de", "language": "de"}]`,
+			metadata:     existingMetadata,
+			existing:     `["doc"]`,
+			wantStatus:   "repaired",
+			wantAdded:    "de",
+			wantRemoved:  "it",
+			check: func(t *testing.T, mutation gjson.Result) {
+				patch := mutation.Get("patch")
+				if patch.Get("ifRevisionID").String() != "rev-2" || patch.Get("set.translations.#._key").String() != `["en","de"]` {
+					t.Errorf("unexpected patch: %s", patch.Raw)
+				}
+			},
+		},
+		{
+			name:         "AdoptBaseMetadata",
+			translations: `[{"_id": "doc_it", "language": "it"}]`,
+			metadata:     `{"_id": "doc_base", "_type": "translation.metadata", "_rev": "rev-1", "translations": [{"_key": "it", "value": {"_ref": "doc_it", "_type": "reference"}}]}`,
+			existing:     `["doc_it"]`,
+			wantStatus:   "repaired",
+			check: func(t *testing.T, mutation gjson.Result) {
+				patch := mutation.Get("patch")
+				if patch.Get("id").String() != "doc_base" || patch.Get("set.translations.#._key").String() != `["en","it"]` {
+					t.Errorf("the source should be linked: %s", patch.Raw)
+				}
+			},
+		},
+		{
+			name:         "OtherDocumentWithBaseID",
+			translations: `[{"_id": "doc_it", "language": "it"}]`,
+			metadata:     `{"_id": "doc_base", "_type": "page"}`,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeSanity(t, func(query string) string {
+				if strings.Contains(query, "$ids") {
+					return tt.existing
+				}
+				return `{"source": {"_id": "doc", "_type": "test", "language": "en"}, "translations": ` + tt.translations + `, "metadata": ` + tt.metadata + `}`
+			})
+
+			repair, err := RepairTranslationMetadata("", "doc", false)
+			if tt.wantErr {
+				if err == nil || len(fake.mutations) != 0 {
+					t.Errorf("%s: RepairTranslationMetadata() = %v after %d mutations, want an error and none", tt.name, err, len(fake.mutations))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: RepairTranslationMetadata() returned an error: %v", tt.name, err)
+			}
+			if repair.Status != tt.wantStatus || strings.Join(repair.Added, ",") != tt.wantAdded || strings.Join(repair.Removed, ",") != tt.wantRemoved {
+				t.Errorf("%s: repair = %+v", tt.name, repair)
+			}
+			if tt.check == nil {
+				if len(fake.mutations) != 0 {
+					t.Errorf("%s: sent %d mutate requests, want none", tt.name, len(fake.mutations))
+				}
+				return
+			}
+			if len(fake.mutations) != 1 {
+				t.Fatalf("%s: sent %d mutate requests, want 1", tt.name, len(fake.mutations))
+			}
+			tt.check(t, gjson.Get(fake.mutations[0], "mutations.0"))
+		})
+	}
+}
+
+func TestRepairTranslationMetadataConcurrentChange(t *testing.T) {
+	// The metadata gains a French translation between the first read and the patch
+	changed := strings.Replace(existingMetadata, `"rev-2"`, `"rev-3"`, 1)
+	changed = strings.Replace(changed, `]`, `, {"_key": "fr", "value": {"_ref": "doc_fr", "_type": "reference"}}]`, 1)
+	var fake *fakeSanity
+	fake = useFakeSanity(t, func(query string) string {
+		if strings.Contains(query, "$ids") {
+			return `["doc", "old_it", "doc_fr"]`
+		}
+		metadata := existingMetadata
+		if len(fake.mutations) > 0 {
+			metadata = changed
+		}
+		return `{"source": {"_id": "doc", "_type": "test", "language": "en"}, "translations": [{"_id": "doc_de", "language": "de"}], "metadata": ` + metadata + `}`
+	})
+	fake.conflicts = 1
+
+	repair, err := RepairTranslationMetadata("", "doc", false)
+	if err != nil {
+		t.Fatalf("RepairTranslationMetadata() returned an error: %v", err)
+	}
+	if len(fake.mutations) != 2 {
+		t.Fatalf("sent %d mutate requests, want 2", len(fake.mutations))
+	}
+	patch := gjson.Get(fake.mutations[1], "mutations.0.patch")
+	if patch.Get("ifRevisionID").String() != "rev-3" || patch.Get("set.translations.#._key").String() != `["en","it","fr","de"]` {
+		t.Errorf("retried patch = %s, want the concurrent change kept", patch.Raw)
+	}
+	if repair.Status != "repaired" || strings.Join(repair.Added, ",") != "de" {
+		t.Errorf("repair = %+v", repair)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// isPortableTextBlock reports whether val is a Portable Text block with children spans.
+func isPortableTextBlock(val map[string]interface{}) bool {
+	if val["_type"] != "block" {
+		return false
+	}
+	_, ok := val["children"].([]interface{})
+	return ok
+}
+
+// SerializeBlock turns the children of a Portable Text block into a single XML string,
+// one <span> element per child, so that DeepL translates the sentence as a whole.
+// Non-text children are emitted as empty elements and restored untouched by ParseBlock.
+func SerializeBlock(children []interface{}) string {
+	var sb strings.Builder
+	for i, child := range children {
+		span, _ := child.(map[string]interface{})
+		text, isText := span["text"].(string)
+		if !isText {
+			fmt.Fprintf(&sb, `<span i="%d"/>`, i)
+			continue
+		}
+		marks := []string{}
+		if rawMarks, ok := span["marks"].([]interface{}); ok {
+			for _, mark := range rawMarks {
+				marks = append(marks, fmt.Sprintf("%v", mark))
+			}
+		}
+		if len(marks) > 0 {
+			fmt.Fprintf(&sb, `<span i="%d" marks="%s">`, i, xmlEscape(strings.Join(marks, " ")))
+		} else {
+			fmt.Fprintf(&sb, `<span i="%d">`, i)
+		}
+		sb.WriteString(xmlEscape(text))
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
+
+// ParseBlock maps a translated XML string produced from SerializeBlock back onto the
+// original children, in the order DeepL put the spans. Every child keeps its _key,
+// _type and marks; only text changes. Text that DeepL moved outside of a span is
+// attached to the closest preceding span, and spans left empty are dropped.
+func ParseBlock(translated string, children []interface{}) ([]interface{}, error) {
+	texts := make([]strings.Builder, len(children))
+	seen := make([]bool, len(children))
+	order := []int{}
+
+	decoder := xml.NewDecoder(strings.NewReader("<block>" + translated + "</block>"))
+	current := -1
+	last := -1
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing translated block: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "span" {
+				continue
+			}
+			index := -1
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "i" {
+					index, _ = strconv.Atoi(attr.Value)
+				}
+			}
+			if index < 0 || index >= len(children) {
+				return nil, fmt.Errorf("unknown span index in translated block: %d", index)
+			}
+			if !seen[index] {
+				seen[index] = true
+				order = append(order, index)
+			}
+			current = index
+			last = index
+		case xml.EndElement:
+			if t.Name.Local == "span" {
+				current = -1
+			}
+		case xml.CharData:
+			target := current
+			if target == -1 {
+				target = last
+			}
+			if target == -1 && len(order) == 0 {
+				// Leading text before any span goes to the first text child
+				for i := range children {
+					if _, ok := children[i].(map[string]interface{})["text"].(string); ok {
+						target = i
+						break
+					}
+				}
+			}
+			if target >= 0 {
+				texts[target].Write(t)
+			}
+		}
+	}
+
+	// Children dropped by the provider are only kept when they are not text spans
+	for i := range children {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+
+	result := make([]interface{}, 0, len(children))
+	firstText := -1
+	for _, i := range order {
+		original, ok := children[i].(map[string]interface{})
+		if !ok {
+			result = append(result, children[i])
+			continue
+		}
+		if _, isText := original["text"].(string); !isText {
+			result = append(result, original)
+			continue
+		}
+		if firstText == -1 {
+			firstText = i
+		}
+		if texts[i].Len() == 0 {
+			continue
+		}
+		result = append(result, withText(original, texts[i].String()))
+	}
+	// A block keeps at least one span
+	if len(result) == 0 && firstText >= 0 {
+		result = append(result, withText(children[firstText].(map[string]interface{}), ""))
+	}
+	return result, nil
+}
+
+// withText returns a copy of span with text
+func withText(span map[string]interface{}, text string) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for key, value := range span {
+		copied[key] = value
+	}
+	copied["text"] = text
+	return copied
+}
+
+// xmlEscape escapes text so it can be embedded in an XML element or attribute.
+func xmlEscape(text string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(text))
+	return sb.String()
+}
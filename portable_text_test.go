@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func testBlockChildren(t *testing.T) []interface{} {
+	var children []interface{}
+	err := json.Unmarshal([]byte(`[
+		{"_key": "a1", "_type": "span", "marks": [], "text": "This is "},
+		{"_key": "b2", "_type": "span", "marks": ["strong", "link1"], "text": "very <important>"},
+		{"_key": "c3", "_type": "span", "marks": [], "text": " & fun."}
+	]`), &children)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal children: %v", err)
+	}
+	return children
+}
+
+func TestSerializeBlock(t *testing.T) {
+	got := SerializeBlock(testBlockChildren(t))
+	want := `<span i="0">This is </span><span i="1" marks="strong link1">very &lt;important&gt;</span><span i="2"> &amp; fun.</span>`
+	if got != want {
+		t.Errorf("SerializeBlock() = %v, want %v", got, want)
+	}
+}
+
+func TestParseBlock(t *testing.T) {
+	tests := []struct {
+		name       string
+		translated string
+		wantKeys   []string
+		wantTexts  []string
+	}{
+		{
+			name:       "SameOrder",
+			translated: `<span i="0">Questo è </span><span i="1" marks="strong link1">molto &lt;importante&gt;</span><span i="2"> e divertente.</span>`,
+			wantKeys:   []string{"a1", "b2", "c3"},
+			wantTexts:  []string{"Questo è ", "molto <importante>", " e divertente."},
+		},
+		{
+			name:       "ReorderedWithTextBetweenSpans",
+			translated: `<span i="1" marks="strong link1">Sehr wichtig</span> <span i="0">und</span><span i="2"> lustig.</span>`,
+			wantKeys:   []string{"b2", "a1", "c3"},
+			wantTexts:  []string{"Sehr wichtig ", "und", " lustig."},
+		},
+		{
+			name:       "DroppedSpan",
+			translated: `<span i="0">Questo è molto importante</span><span i="2">.</span>`,
+			wantKeys:   []string{"a1", "c3"},
+			wantTexts:  []string{"Questo è molto importante", "."},
+		},
+		{
+			name:       "EmptiedSpan",
+			translated: `<span i="2">Divertente</span><span i="1" marks="strong link1"></span><span i="0"> davvero.</span>`,
+			wantKeys:   []string{"c3", "a1"},
+			wantTexts:  []string{"Divertente", " davvero."},
+		},
+		{
+			name:       "EverySpanDropped",
+			translated: ``,
+			wantKeys:   []string{"a1"},
+			wantTexts:  []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseBlock(tt.translated, testBlockChildren(t))
+			if err != nil {
+				t.Fatalf("%s: ParseBlock() returned an error: %v", tt.name, err)
+			}
+			if len(result) != len(tt.wantKeys) {
+				t.Fatalf("%s: ParseBlock() returned %d spans, want %d", tt.name, len(result), len(tt.wantKeys))
+			}
+			for i, key := range tt.wantKeys {
+				span := result[i].(map[string]interface{})
+				if span["_key"] != key {
+					t.Errorf("%s: span %d _key = %v, want %v", tt.name, i, span["_key"], key)
+				}
+				if span["text"] != tt.wantTexts[i] {
+					t.Errorf("%s: span %d text = %q, want %q", tt.name, i, span["text"], tt.wantTexts[i])
+				}
+			}
+			for _, child := range result {
+				span := child.(map[string]interface{})
+				if span["_key"] == "b2" && len(span["marks"].([]interface{})) != 2 {
+					t.Errorf("%s: marks of b2 were not preserved: %v", tt.name, span["marks"])
+				}
+			}
+		})
+	}
+}
+
+func TestPortableTextModeTranslatesWholeBlocks(t *testing.T) {
+	fake := &fakeTranslator{prefix: ""}
+	originalTranslator := ActiveTranslator
+	ActiveTranslator = fake
+	defer func() { ActiveTranslator = originalTranslator }()
+
+	before := `{
+		"_id": "doc",
+		"portableTest": [
+			{
+				"_key": "block1",
+				"_type": "block",
+				"style": "normal",
+				"markDefs": [{"_key": "link1", "_type": "link", "href": "https://example.com"}],
+				"children": [
+					{"_key": "a1", "_type": "span", "marks": [], "text": "Read "},
+					{"_key": "b2", "_type": "span", "marks": ["link1"], "text": "the docs"}
+				]
+			}
+		]
+	}`
+	txx := SanityDocumentTranslator{
+		FromLang:      "en",
+		ToLang:        "it",
+		PortableText:  true,
+		InputElements: []InputElement{{Path: "portableTest.000.children.000.text"}},
+		Before:        before,
+		After:         before,
+	}
+
+	var document map[string]interface{}
+	json.Unmarshal([]byte(before), &document)
+	if err := ExecuteTranslation(&txx, document, ""); err != nil {
+		t.Fatalf("ExecuteTranslation() returned an error: %v", err)
+	}
+	if len(txx.Fields) != 1 || txx.Fields[0].Kind != FieldPortableBlock {
+		t.Fatalf("ExecuteTranslation() fields = %+v, want one block", txx.Fields)
+	}
+	if err := TranslateFields(context.Background(), &txx); err != nil {
+		t.Fatalf("TranslateFields() returned an error: %v", err)
+	}
+	if fake.options[0].TagHandling != "xml" {
+		t.Errorf("TranslateFields() tag handling = %q, want xml", fake.options[0].TagHandling)
+	}
+	if err := ApplyFields(&txx); err != nil {
+		t.Fatalf("ApplyFields() returned an error: %v", err)
+	}
+
+	if gjson.Get(txx.After, "portableTest.0.children.1.marks.0").String() != "link1" {
+		t.Errorf("marks were not preserved: %s", txx.After)
+	}
+	if gjson.Get(txx.After, "portableTest.0.markDefs.0._key").String() != "link1" {
+		t.Errorf("markDefs were not preserved: %s", txx.After)
+	}
+	if gjson.Get(txx.After, "portableTest.0.children.1.text").String() != "the docs" {
+		t.Errorf("span text was not restored: %s", txx.After)
+	}
+}
+
+func TestPortableTextBlockSelection(t *testing.T) {
+	before := `{
+		"portableTest": [
+			{
+				"_key": "block1",
+				"_type": "block",
+				"children": [
+					{"_key": "a1", "_type": "span", "marks": [], "text": "Read "},
+					{"_key": "b2", "_type": "span", "marks": ["link1"], "text": "the docs"}
+				]
+			}
+		]
+	}`
+
+	tests := []struct {
+		name     string
+		selector string
+		want     int
+	}{
+		{name: "FirstChild", selector: "portableTest[0].children[0].text", want: 1},
+		{name: "OtherChild", selector: "portableTest[0].children[1].text", want: 1},
+		{name: "BlockPath", selector: "portableTest[*]", want: 1},
+		{name: "OtherField", selector: "title", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txx := SanityDocumentTranslator{
+				PortableText:  true,
+				InputElements: []InputElement{{Path: tt.selector}},
+				Before:        before,
+			}
+			var document map[string]interface{}
+			json.Unmarshal([]byte(before), &document)
+			if err := ExecuteTranslation(&txx, document, ""); err != nil {
+				t.Fatalf("%s: ExecuteTranslation() returned an error: %v", tt.name, err)
+			}
+			if len(txx.Fields) != tt.want {
+				t.Fatalf("%s: collected %d fields, want %d: %+v", tt.name, len(txx.Fields), tt.want, txx.Fields)
+			}
+			if tt.want > 0 && (txx.Fields[0].Path != "portableTest.0" || txx.Fields[0].Kind != FieldPortableBlock) {
+				t.Errorf("%s: field = %+v, want the block", tt.name, txx.Fields[0])
+			}
+		})
+	}
+}
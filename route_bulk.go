@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SanityBulkTranslate queues the translation of every document matching a GROQ filter.
+func SanityBulkTranslate(c *gin.Context) {
+	var req BulkRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "Failed binding event to JSON")
+		fmt.Fprintln(logOutput, "Failed binding event to JSON")
+		return
+	}
+
+	if err := ValidateBulkRequest(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		fmt.Fprintln(logOutput, err)
+		return
+	}
+
+	report := &BulkReport{Cursor: req.Cursor}
+	if req.ResumeJobID != "" {
+		previous, ok := ActiveJobs.Get(req.ResumeJobID)
+		if !ok || !previous.Finished() {
+			c.String(http.StatusBadRequest, "Job to resume not found or still running")
+			fmt.Fprintln(logOutput, "Job to resume not found or still running:", req.ResumeJobID)
+			return
+		}
+		previousReport, ok := previous.Snapshot().Result.(*BulkReport)
+		if !ok {
+			c.String(http.StatusBadRequest, "Job to resume is not a bulk translation")
+			fmt.Fprintln(logOutput, "Job to resume is not a bulk translation:", req.ResumeJobID)
+			return
+		}
+		report = previousReport.snapshot()
+		fmt.Fprintf(logOutput, "Resuming bulk translation after: %s\n", report.Cursor)
+	}
+
+	EnqueueJob(c, "Bulk translation of "+req.Filter, func(ctx context.Context, job *Job) (interface{}, error) {
+		job.SetResult(report.snapshot())
+		err := RunBulk(ctx, &req, report, func(outcome BulkOutcome) {
+			job.SetResult(report.snapshot())
+			job.Emit("document", outcome)
+		})
+		return report, err
+	})
+}
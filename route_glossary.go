@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// glossaryManager returns the active provider as GlossaryManager, or aborts the request.
+func glossaryManager(c *gin.Context) (GlossaryManager, bool) {
+	manager, ok := ActiveTranslator.(GlossaryManager)
+	if !ok {
+		c.String(http.StatusNotImplemented, "Translation provider does not support glossaries")
+		fmt.Fprintln(logOutput, "Translation provider does not support glossaries")
+		return nil, false
+	}
+	return manager, true
+}
+
+// CreateGlossary creates a glossary from CSV/TSV term pairs.
+func CreateGlossary(c *gin.Context) {
+	var req GlossaryRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "Failed binding event to JSON")
+		fmt.Fprintln(logOutput, "Failed binding event to JSON")
+		return
+	}
+	if req.EntriesFormat == "" {
+		req.EntriesFormat = "tsv"
+	}
+
+	manager, ok := glossaryManager(c)
+	if !ok {
+		return
+	}
+
+	glossary, err := manager.CreateGlossary(
+		req.Name,
+		req.SourceLang,
+		req.TargetLang,
+		req.Entries,
+		req.EntriesFormat,
+	)
+	if err != nil {
+		AbortWithTranslationError(c, "Failed creating glossary", err)
+		return
+	}
+
+	if req.Default {
+		err = DefaultGlossaries.Set(req.SourceLang, req.TargetLang, glossary.GlossaryID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Failed storing default glossary")
+			fmt.Fprintln(logOutput, "Failed storing default glossary:", err)
+			return
+		}
+	}
+
+	fmt.Fprintf(logOutput, "Created glossary: %s\n", glossary.GlossaryID)
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":   "success",
+			"message":  "Glossary created",
+			"glossary": glossary,
+		},
+	)
+}
+
+// FetchGlossaries lists the glossaries of the translation provider.
+func FetchGlossaries(c *gin.Context) {
+	manager, ok := glossaryManager(c)
+	if !ok {
+		return
+	}
+
+	glossaries, err := manager.ListGlossaries()
+	if err != nil {
+		AbortWithTranslationError(c, "Failed listing glossaries", err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":     "success",
+			"glossaries": glossaries,
+			"defaults":   DefaultGlossaries.All(),
+		},
+	)
+}
+
+// RemoveGlossary deletes a glossary from the translation provider.
+func RemoveGlossary(c *gin.Context) {
+	manager, ok := glossaryManager(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	err := manager.DeleteGlossary(id)
+	if err != nil {
+		AbortWithTranslationError(c, "Failed deleting glossary", err)
+		return
+	}
+	err = DefaultGlossaries.Remove(id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed removing the defaults of the deleted glossary")
+		fmt.Fprintln(logOutput, "Failed removing the defaults of the deleted glossary:", err)
+		return
+	}
+
+	fmt.Fprintf(logOutput, "Deleted glossary: %s\n", id)
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":  "success",
+			"message": "Glossary deleted",
+		},
+	)
+}
+
+// SetDefaultGlossary stores the default glossary of a language pair.
+func SetDefaultGlossary(c *gin.Context) {
+	var req GlossaryDefaultRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "Failed binding event to JSON")
+		fmt.Fprintln(logOutput, "Failed binding event to JSON")
+		return
+	}
+	if req.SourceLang == "" || req.TargetLang == "" {
+		c.String(http.StatusBadRequest, "SourceLang and TargetLang are required")
+		fmt.Fprintln(logOutput, "SourceLang and TargetLang are required")
+		return
+	}
+
+	err := DefaultGlossaries.Set(req.SourceLang, req.TargetLang, req.GlossaryID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed storing default glossary")
+		fmt.Fprintln(logOutput, "Failed storing default glossary:", err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":   "success",
+			"message":  "Default glossary updated",
+			"defaults": DefaultGlossaries.All(),
+		},
+	)
+}
@@ -15,5 +15,5 @@ func FetchHealth(c *gin.Context) {
 			"message": "API is healthy",
 		},
 	)
-	fmt.Println("Health check done")
+	fmt.Fprintln(logOutput, "Health check done")
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// EnqueueJob queues run on ActiveJobs and responds with the job id.
+func EnqueueJob(c *gin.Context, description string, run JobFunc) {
+	job, err := ActiveJobs.Enqueue(description, run)
+	if err != nil {
+		c.String(http.StatusServiceUnavailable, "Failed queueing job: "+err.Error())
+		fmt.Fprintln(logOutput, "Failed queueing job:", err)
+		return
+	}
+
+	fmt.Fprintf(logOutput, "Queued job %s: %s\n", job.ID, description)
+	c.JSON(
+		http.StatusAccepted,
+		gin.H{
+			"status":  "success",
+			"message": "Job queued",
+			"jobId":   job.ID,
+		},
+	)
+}
+
+// FetchJob reports the status, progress and result of a job.
+func FetchJob(c *gin.Context) {
+	job, ok := ActiveJobs.Get(c.Param("id"))
+	if !ok {
+		c.String(http.StatusNotFound, "Job not found")
+		fmt.Fprintln(logOutput, "Job not found:", c.Param("id"))
+		return
+	}
+	c.JSON(http.StatusOK, job.Snapshot())
+}
+
+// CancelJob stops a queued or running job.
+func CancelJob(c *gin.Context) {
+	job, cancelled := ActiveJobs.Cancel(c.Param("id"))
+	if job == nil {
+		c.String(http.StatusNotFound, "Job not found")
+		fmt.Fprintln(logOutput, "Job not found:", c.Param("id"))
+		return
+	}
+	if !cancelled {
+		c.String(http.StatusConflict, "Job already finished")
+		fmt.Fprintln(logOutput, "Job already finished:", job.ID)
+		return
+	}
+
+	fmt.Fprintf(logOutput, "Cancelled job %s\n", job.ID)
+	c.JSON(http.StatusOK, job.Snapshot())
+}
+
+// StreamJobEvents streams the events of a job as Server-Sent Events until it finishes.
+// Clients reconnecting with Last-Event-ID only receive the events they missed.
+func StreamJobEvents(c *gin.Context) {
+	job, ok := ActiveJobs.Get(c.Param("id"))
+	if !ok {
+		c.String(http.StatusNotFound, "Job not found")
+		fmt.Fprintln(logOutput, "Job not found:", c.Param("id"))
+		return
+	}
+
+	// Set before the first flush: a queued job has no event to render yet
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	lastID, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	c.Stream(func(w io.Writer) bool {
+		events, changed, finished := job.EventsAfter(lastID)
+		for _, event := range events {
+			c.Render(-1, sse.Event{
+				Id:    strconv.Itoa(event.ID),
+				Event: event.Type,
+				Data:  event.Data,
+			})
+			lastID = event.ID
+		}
+		if finished {
+			return false
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-changed:
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
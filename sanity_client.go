@@ -2,49 +2,196 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
 )
 
-func RunQuery(query string) (string, error) {
-	response, err := HTTPRequest("GET", "query", query)
+const (
+	SanityDefaultDataset    = "production"
+	SanityDefaultAPIHost    = "api.sanity.io"
+	SanityDefaultCDNHost    = "apicdn.sanity.io"
+	SanityDefaultAPIVersion = "v2021-06-07"
+)
+
+// SanityClient holds the configuration used to reach a Sanity dataset.
+type SanityClient struct {
+	ProjectID  string
+	Dataset    string // Dataset to read and write (e.g. production, staging)
+	APIVersion string // API version (e.g. v2023-08-01)
+	Host       string // API host (e.g. api.sanity.io)
+	CDNHost    string // Host used for reads when UseCDN is set
+	UseCDN     bool   // Send queries through the API CDN
+	Token      string
+	APIURL     string // Overrides the URL built from ProjectID, Host and APIVersion (e.g. in tests)
+}
+
+// SanityError is returned when the Sanity API answers with a non-200 status.
+type SanityError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *SanityError) Error() string {
+	return fmt.Sprintf("received non-200 status code: %d: %s", e.StatusCode, e.Body)
+}
+
+// isRevisionConflict reports whether err is the 409 returned when an ifRevisionID check fails
+// or a created document already exists.
+func isRevisionConflict(err error) bool {
+	var sanityErr *SanityError
+	return errors.As(err, &sanityErr) && sanityErr.StatusCode == http.StatusConflict
+}
+
+// ActiveSanity is the client used by the HTTP handlers.
+var ActiveSanity = NewSanityClientFromEnv()
+
+// NewSanityClientFromEnv returns a client configured by the SANITY_* environment variables.
+func NewSanityClientFromEnv() *SanityClient {
+	return &SanityClient{
+		ProjectID:  os.Getenv("SANITY_PROJECT_ID"),
+		Dataset:    envOrDefault("SANITY_DATASET", SanityDefaultDataset),
+		APIVersion: envOrDefault("SANITY_VERSION", SanityDefaultAPIVersion),
+		Host:       envOrDefault("SANITY_API_HOST", SanityDefaultAPIHost),
+		CDNHost:    envOrDefault("SANITY_CDN_HOST", SanityDefaultCDNHost),
+		UseCDN:     os.Getenv("SANITY_USE_CDN") == "true",
+		Token:      os.Getenv("SANITY_TOKEN"),
+	}
+}
+
+// envOrDefault returns the environment variable key, or fallback when it is unset.
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// WithDataset returns a copy of the client using dataset, or the client itself when dataset is empty.
+func (s *SanityClient) WithDataset(dataset string) *SanityClient {
+	if dataset == "" || dataset == s.Dataset {
+		return s
+	}
+	client := *s
+	client.Dataset = dataset
+	return &client
+}
+
+// WithoutCDN returns a copy of the client sending queries to the API host, or the client
+// itself when it does not use the CDN. Reads whose result feeds a mutation (the _rev checked
+// by ifRevisionID, the documents a patch is planned from) must not be served stale.
+func (s *SanityClient) WithoutCDN() *SanityClient {
+	if !s.UseCDN {
+		return s
+	}
+	client := *s
+	client.UseCDN = false
+	return &client
+}
+
+// sanityFor returns the active client switched to the dataset requested, if any.
+func sanityFor(dataset string) *SanityClient {
+	return ActiveSanity.WithDataset(dataset)
+}
+
+// RunQuery runs a GROQ query on the active client.
+func RunQuery(query string, params map[string]interface{}) (string, error) {
+	return ActiveSanity.Query(query, params)
+}
+
+// RunMutation submits mutations on the active client.
+func RunMutation(mutations ...Mutation) error {
+	_, err := ActiveSanity.Mutate(mutations...)
+	return err
+}
+
+// Query runs a GROQ query. Each entry of params is bound to $name in the query
+// and sent JSON-encoded as a $name query-string value, so values are never interpolated.
+func (s *SanityClient) Query(query string, params map[string]interface{}) (string, error) {
+	values := url.Values{}
+	values.Set("query", query)
+	for name, value := range params {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("error encoding query parameter %s: %w", name, err)
+		}
+		values.Set("$"+name, string(encoded))
+	}
+
+	response, err := s.HTTPRequest("GET", "query", values.Encode(), "")
 	if err != nil {
-		fmt.Println("Error querying document:", query)
+		fmt.Fprintln(logOutput, "Error querying document:", query)
 		return "", err
 	}
 	// fmt.Println("Successfully queried document")
 	return response, nil
 }
 
-func RunMutation(mutationData string) error {
-	_, err := HTTPRequest("POST", "mutate", mutationData)
+// Mutate submits the mutations as a single transaction.
+func (s *SanityClient) Mutate(mutations ...Mutation) (MutationResult, error) {
+	return s.MutateWithOptions(MutationOptions{}, mutations...)
+}
+
+// MutateWithOptions submits the mutations as a single transaction using opts.
+func (s *SanityClient) MutateWithOptions(opts MutationOptions, mutations ...Mutation) (MutationResult, error) {
+	var result MutationResult
+
+	mutationData, err := marshalMutations(mutations)
+	if err != nil {
+		return result, fmt.Errorf("error encoding mutations: %w", err)
+	}
+
+	response, err := s.HTTPRequest("POST", "mutate", opts.values().Encode(), string(mutationData))
 	if err != nil {
-		fmt.Println("Error mutating document:", mutationData)
-		return err
+		fmt.Fprintln(logOutput, "Error mutating document:", string(mutationData))
+		return result, err
 	}
 	// fmt.Println("Successfully mutated document")
-	return nil
+
+	err = json.Unmarshal([]byte(response), &result)
+	if err != nil {
+		return result, fmt.Errorf("error decoding mutation response: %w", err)
+	}
+	return result, nil
+}
+
+// baseURL returns the data endpoint of the client; reads may go through the CDN.
+func (s *SanityClient) baseURL(method string) string {
+	if s.APIURL != "" {
+		return strings.TrimSuffix(s.APIURL, "/")
+	}
+	host := s.Host
+	if method == "GET" && s.UseCDN {
+		host = s.CDNHost
+	}
+	return fmt.Sprintf("https://%s.%s/%s/data", s.ProjectID, host, s.APIVersion)
 }
 
 // HTTPRequest performs a generic HTTP request and returns the response body as a string.
-func HTTPRequest(method, path, data string) (string, error) {
+// query is the encoded query string, body the JSON body of POST requests.
+func (s *SanityClient) HTTPRequest(method, path, query, body string) (string, error) {
 	fullURL := fmt.Sprintf(
-		BaseAPIURL+"/%s/production",
-		ProjectID,
-		Version,
+		"%s/%s/%s",
+		s.baseURL(method),
 		path,
+		url.PathEscape(s.Dataset),
 	)
+	if query != "" {
+		fullURL += "?" + query
+	}
 
 	var req *http.Request
 	var err error
 
 	if method == "GET" {
-		fullURL += "?query=" + url.QueryEscape(data)
 		req, err = http.NewRequest(method, fullURL, nil)
 	} else if method == "POST" {
-		req, err = http.NewRequest(method, fullURL, bytes.NewBuffer([]byte(data)))
+		req, err = http.NewRequest(method, fullURL, bytes.NewBuffer([]byte(body)))
 	}
 
 	if err != nil {
@@ -52,7 +199,7 @@ func HTTPRequest(method, path, data string) (string, error) {
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+Token)
+	req.Header.Add("Authorization", "Bearer "+s.Token)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -61,14 +208,14 @@ func HTTPRequest(method, path, data string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
+	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("error reading response: %w", err)
 	}
 
-	return string(body), nil
+	if resp.StatusCode != 200 {
+		return "", &SanityError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return string(respBody), nil
 }
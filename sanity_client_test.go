@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/tidwall/gjson"
 )
 
 type SanityResponse struct {
@@ -36,7 +40,7 @@ func TestRunQuery(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			response, err := RunQuery(tt.query)
+			response, err := RunQuery(tt.query, nil)
 
 			var resp SanityResponse
 
@@ -77,26 +81,21 @@ func TestRunMutation(t *testing.T) {
 			randomString := RandomString(10)
 			expectedIntro := "Updated intro text " + randomString
 
-			mutationData := fmt.Sprintf(`
-		 		{
-		 			"mutations": [
-		 				{
-		 					"patch": {
-		 						"id": "%s",
-		 						"set": {
-		 							"intro": "%s"
-		 						}
-		 					}
-		 				}
-		 			]
-		 		}`, tt.documentID, expectedIntro)
-
-			err := RunMutation(mutationData)
+			mutation := Mutation{
+				Patch: &Patch{
+					ID: tt.documentID,
+					Set: map[string]interface{}{
+						"intro": expectedIntro,
+					},
+				},
+			}
+
+			err := RunMutation(mutation)
 			if err != nil {
 				t.Fatalf("%s: RunMutation() returned an error: %v", tt.name, err)
 			}
 
-			response, err := RunQuery(fmt.Sprintf("*[_id == '%s']{intro}", tt.documentID))
+			response, err := RunQuery(fmt.Sprintf("*[_id == '%s']{intro}", tt.documentID), nil)
 			if err != nil {
 				t.Fatalf("%s: RunQuery() returned an error when verifying mutation: %v", tt.name, err)
 			}
@@ -132,3 +131,118 @@ func RandomString(n int) string {
 	}
 	return string(s)
 }
+
+func TestRunQueryParameters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") != "*[slug.current == $slug][0]" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(fmt.Sprintf(`{"result": {"slug": %s}}`, r.URL.Query().Get("$slug"))))
+	}))
+	defer server.Close()
+
+	client := &SanityClient{Dataset: "production", APIURL: server.URL}
+
+	slug := `/en/it's-a "quoted" slug`
+	response, err := client.Query(`*[slug.current == $slug][0]`, map[string]interface{}{"slug": slug})
+	if err != nil {
+		t.Fatalf("Query() returned an error: %v", err)
+	}
+	if got := gjson.Get(response, "result.slug").String(); got != slug {
+		t.Errorf("Query() sent $slug = %q, want %q", got, slug)
+	}
+}
+
+func TestSanityClientURL(t *testing.T) {
+	var gotPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPaths = append(gotPaths, r.URL.Path)
+		w.Write([]byte(`{"result": null, "transactionId": "tx"}`))
+	}))
+	defer server.Close()
+
+	client := &SanityClient{Dataset: "production", APIURL: server.URL + "/v2023-08-01/data"}
+	client.WithDataset("staging").Query(`*[_type == "test"]`, nil)
+	client.Mutate(Mutation{Delete: &Delete{ID: "doc"}})
+
+	want := []string{"/v2023-08-01/data/query/staging", "/v2023-08-01/data/mutate/production"}
+	if len(gotPaths) != 2 || gotPaths[0] != want[0] || gotPaths[1] != want[1] {
+		t.Errorf("SanityClient requested %v, want %v", gotPaths, want)
+	}
+
+	tests := []struct {
+		name   string
+		client SanityClient
+		method string
+		want   string
+	}{
+		{
+			name:   "API",
+			client: SanityClient{ProjectID: "abc", Host: SanityDefaultAPIHost, CDNHost: SanityDefaultCDNHost, APIVersion: "v2023-08-01"},
+			method: "GET",
+			want:   "https://abc.api.sanity.io/v2023-08-01/data",
+		},
+		{
+			name:   "CDNRead",
+			client: SanityClient{ProjectID: "abc", Host: SanityDefaultAPIHost, CDNHost: SanityDefaultCDNHost, APIVersion: "v2023-08-01", UseCDN: true},
+			method: "GET",
+			want:   "https://abc.apicdn.sanity.io/v2023-08-01/data",
+		},
+		{
+			name:   "CDNWrite",
+			client: SanityClient{ProjectID: "abc", Host: SanityDefaultAPIHost, CDNHost: SanityDefaultCDNHost, APIVersion: "v2023-08-01", UseCDN: true},
+			method: "POST",
+			want:   "https://abc.api.sanity.io/v2023-08-01/data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.client.baseURL(tt.method); got != tt.want {
+				t.Errorf("%s: baseURL() = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	cdn := &SanityClient{ProjectID: "abc", Host: SanityDefaultAPIHost, CDNHost: SanityDefaultCDNHost, APIVersion: "v2023-08-01", UseCDN: true}
+	if got := cdn.WithoutCDN().baseURL("GET"); got != "https://abc.api.sanity.io/v2023-08-01/data" {
+		t.Errorf("WithoutCDN().baseURL() = %v, want the API host", got)
+	}
+	if !cdn.UseCDN {
+		t.Errorf("WithoutCDN() changed the original client")
+	}
+}
+
+func TestMutationMarshalling(t *testing.T) {
+	translated := "Il \"titolo\" \\ con\nnuova riga"
+	body, err := marshalMutations([]Mutation{
+		{CreateOrReplace: json.RawMessage(`{"_id": "doc_it", "_type": "test"}`)},
+		{Patch: &Patch{ID: "doc_it", Set: map[string]interface{}{"text[0].intro": translated}}},
+		{Patch: &Patch{ID: "meta", Unset: []string{"old"}, Inc: map[string]float64{"count": 1}}},
+		{Delete: &Delete{ID: "doc_fr"}},
+	})
+	if err != nil {
+		t.Fatalf("marshalMutations() returned an error: %v", err)
+	}
+	if !json.Valid(body) {
+		t.Fatalf("marshalMutations() produced invalid JSON: %s", body)
+	}
+
+	result := gjson.ParseBytes(body)
+	if got := result.Get("mutations.0.createOrReplace._id").String(); got != "doc_it" {
+		t.Errorf("createOrReplace _id = %q, want doc_it", got)
+	}
+	if got := result.Get(`mutations.1.patch.set.text\[0\]\.intro`).String(); got != translated {
+		t.Errorf("patch set = %q, want %q", got, translated)
+	}
+	if got := result.Get("mutations.2.patch.unset.0").String(); got != "old" {
+		t.Errorf("patch unset = %q, want old", got)
+	}
+	if result.Get("mutations.2.patch.set").Exists() {
+		t.Errorf("empty patch operations should be omitted: %s", body)
+	}
+	if got := result.Get("mutations.3.delete.id").String(); got != "doc_fr" {
+		t.Errorf("delete id = %q, want doc_fr", got)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Mutation is one entry of a Sanity mutate request. Exactly one field must be set.
+// Documents can be any value marshalling to a JSON object, such as
+// map[string]interface{} or json.RawMessage.
+type Mutation struct {
+	Create            interface{} `json:"create,omitempty"`
+	CreateOrReplace   interface{} `json:"createOrReplace,omitempty"`
+	CreateIfNotExists interface{} `json:"createIfNotExists,omitempty"`
+	Patch             *Patch      `json:"patch,omitempty"`
+	Delete            *Delete     `json:"delete,omitempty"`
+}
+
+// Patch modifies an existing document.
+type Patch struct {
+	ID           string                 `json:"id"`
+	IfRevisionID string                 `json:"ifRevisionID,omitempty"` // Fail unless the document is still at this revision
+	Set          map[string]interface{} `json:"set,omitempty"`
+	SetIfMissing map[string]interface{} `json:"setIfMissing,omitempty"`
+	Unset        []string               `json:"unset,omitempty"`
+	Insert       *Insert                `json:"insert,omitempty"`
+	Inc          map[string]float64     `json:"inc,omitempty"`
+}
+
+// Insert adds items to an array relative to the given position. Exactly one of
+// Before, After and Replace must be set (e.g. After: "translations[-1]").
+type Insert struct {
+	Before  string        `json:"before,omitempty"`
+	After   string        `json:"after,omitempty"`
+	Replace string        `json:"replace,omitempty"`
+	Items   []interface{} `json:"items"`
+}
+
+// Delete removes a document.
+type Delete struct {
+	ID string `json:"id"`
+}
+
+// MutationOptions are the query-string options of a mutate request.
+type MutationOptions struct {
+	DryRun bool // Validate the mutations without committing them
+}
+
+func (o MutationOptions) values() url.Values {
+	values := url.Values{}
+	if o.DryRun {
+		values.Set("dryRun", "true")
+	}
+	return values
+}
+
+// MutationResult is the response of a mutate request.
+type MutationResult struct {
+	TransactionID string `json:"transactionId"`
+	Results       []struct {
+		ID        string `json:"id"`
+		Operation string `json:"operation"`
+	} `json:"results"`
+}
+
+// marshalMutations builds the body of a mutate request.
+func marshalMutations(mutations []Mutation) ([]byte, error) {
+	return json.Marshal(struct {
+		Mutations []Mutation `json:"mutations"`
+	}{mutations})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Kinds of SelectorStep
+const (
+	stepKey      = iota // A named key (title)
+	stepAnyKey          // Any single key or index (*)
+	stepDeep            // Zero or more keys or indexes (**)
+	stepIndex           // A given array index ([2])
+	stepAnyIndex        // Any array index ([*], or a legacy plain number like .000)
+	stepFilter          // Array items whose field equals a value ([_type=="hero"])
+)
+
+// Selector is a parsed InputElements path, e.g. text[*].children[*].text,
+// **.title, sections[_type=="hero"].heading or !metadata.metaKeyword.
+type Selector struct {
+	Raw     string
+	Exclude bool // Selector starts with "!"
+	Steps   []SelectorStep
+}
+
+type SelectorStep struct {
+	Kind        int
+	Key         string // Key for stepKey, filtered field for stepFilter
+	Index       int    // Index for stepIndex
+	FilterValue string // Expected value for stepFilter
+}
+
+// ParseSelector parses a selector string.
+// Plain numeric segments (text.000.title) match any index to stay compatible
+// with existing requests; use text[0].title to target one item.
+func ParseSelector(raw string) (Selector, error) {
+	selector := Selector{Raw: raw}
+	input := strings.TrimSpace(raw)
+	if strings.HasPrefix(input, "!") {
+		selector.Exclude = true
+		input = strings.TrimSpace(input[1:])
+	}
+	if input == "" {
+		return selector, fmt.Errorf("empty selector: %q", raw)
+	}
+
+	for _, segment := range splitSelector(input) {
+		if segment == "" {
+			return selector, fmt.Errorf("empty segment in selector: %q", raw)
+		}
+
+		name := segment
+		brackets := ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			name = segment[:i]
+			brackets = segment[i:]
+		}
+
+		switch {
+		case name == "**":
+			selector.Steps = append(selector.Steps, SelectorStep{Kind: stepDeep})
+		case name == "*":
+			selector.Steps = append(selector.Steps, SelectorStep{Kind: stepAnyKey})
+		case isDigits(name):
+			selector.Steps = append(selector.Steps, SelectorStep{Kind: stepAnyIndex})
+		case name != "":
+			selector.Steps = append(selector.Steps, SelectorStep{Kind: stepKey, Key: name})
+		}
+
+		for brackets != "" {
+			end := strings.Index(brackets, "]")
+			if !strings.HasPrefix(brackets, "[") || end < 0 {
+				return selector, fmt.Errorf("unbalanced brackets in selector: %q", raw)
+			}
+			step, err := parseBracket(brackets[1:end])
+			if err != nil {
+				return selector, fmt.Errorf("%w in selector: %q", err, raw)
+			}
+			selector.Steps = append(selector.Steps, step)
+			brackets = brackets[end+1:]
+		}
+	}
+	return selector, nil
+}
+
+// splitSelector splits on dots that are not inside brackets.
+func splitSelector(input string) []string {
+	segments := []string{}
+	depth := 0
+	start := 0
+	for i, r := range input {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, input[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, input[start:])
+}
+
+// parseBracket parses the content of [...]: an index, * or field=="value".
+func parseBracket(content string) (SelectorStep, error) {
+	content = strings.TrimSpace(content)
+	if content == "*" {
+		return SelectorStep{Kind: stepAnyIndex}, nil
+	}
+	if isDigits(content) {
+		index, _ := strconv.Atoi(content)
+		return SelectorStep{Kind: stepIndex, Index: index}, nil
+	}
+	parts := strings.SplitN(content, "==", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return SelectorStep{}, fmt.Errorf("invalid filter [%s]", content)
+	}
+	value := strings.TrimSpace(parts[1])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return SelectorStep{
+		Kind:        stepFilter,
+		Key:         strings.TrimSpace(parts[0]),
+		FilterValue: value,
+	}, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the dotted document path (e.g. text.0.children.1.text) is selected.
+// document is the raw JSON used to evaluate filters; it may be empty when no filter is used.
+func (s Selector) Match(path string, document string) bool {
+	return matchSteps(s.Steps, strings.Split(path, "."), 0, document)
+}
+
+func matchSteps(steps []SelectorStep, segments []string, consumed int, document string) bool {
+	if len(steps) == 0 {
+		return consumed == len(segments)
+	}
+	step := steps[0]
+
+	if step.Kind == stepDeep {
+		for i := consumed; i <= len(segments); i++ {
+			if matchSteps(steps[1:], segments, i, document) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if consumed == len(segments) {
+		return false
+	}
+	segment := segments[consumed]
+
+	switch step.Kind {
+	case stepKey:
+		if segment != step.Key {
+			return false
+		}
+	case stepAnyKey:
+	case stepIndex:
+		if segment != strconv.Itoa(step.Index) {
+			return false
+		}
+	case stepAnyIndex:
+		if !isDigits(segment) {
+			return false
+		}
+	case stepFilter:
+		if !isDigits(segment) {
+			return false
+		}
+		item := gjson.Get(document, strings.Join(segments[:consumed+1], "."))
+		if item.Get(step.Key).String() != step.FilterValue {
+			return false
+		}
+	}
+	return matchSteps(steps[1:], segments, consumed+1, document)
+}
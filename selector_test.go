@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+const selectorTestDocument = `{
+	"title": "Title",
+	"metatitle": "Meta title",
+	"meta": {"title": "Nested meta title"},
+	"sections": [
+		{"_type": "hero", "heading": "Hero heading"},
+		{"_type": "faq", "heading": "FAQ heading"}
+	]
+}`
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		wantErr  bool
+		wantLen  int
+	}{
+		{name: "Plain", selector: "title", wantLen: 1},
+		{name: "Wildcards", selector: "text[*].children[*].text", wantLen: 5},
+		{name: "Legacy", selector: "text.000.children.000.text ", wantLen: 5},
+		{name: "Deep", selector: "**.title", wantLen: 2},
+		{name: "Filter", selector: `sections[_type=="hero"].heading`, wantLen: 3},
+		{name: "Exclusion", selector: "!metadata.metaKeyword", wantLen: 2},
+		{name: "Empty", selector: " ", wantErr: true},
+		{name: "EmptySegment", selector: "text..title", wantErr: true},
+		{name: "Unbalanced", selector: "text[0.title", wantErr: true},
+		{name: "InvalidFilter", selector: "sections[_type].heading", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ParseSelector(tt.selector)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%s: ParseSelector() expected an error", tt.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: ParseSelector() returned an error: %v", tt.name, err)
+			}
+			if len(selector.Steps) != tt.wantLen {
+				t.Errorf("%s: ParseSelector() = %d steps, want %d", tt.name, len(selector.Steps), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestSelectorMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		path     string
+		want     bool
+	}{
+		{name: "ExactKey", selector: "title", path: "title", want: true},
+		{name: "NoCollision", selector: "meta.title", path: "metatitle", want: false},
+		{name: "NestedKey", selector: "meta.title", path: "meta.title", want: true},
+		{name: "AnyIndex", selector: "text[*].children[*].text", path: "text.3.children.12.text", want: true},
+		{name: "AnyIndexNotKey", selector: "text[*].title", path: "text.intro.title", want: false},
+		{name: "LegacyIndex", selector: "text.000.children.000.text", path: "text.4.children.2.text", want: true},
+		{name: "ScopedIndex", selector: "text[1].title", path: "text.1.title", want: true},
+		{name: "ScopedIndexOther", selector: "text[1].title", path: "text.0.title", want: false},
+		{name: "DeepTopLevel", selector: "**.title", path: "title", want: true},
+		{name: "DeepNested", selector: "**.title", path: "sections.0.cards.2.title", want: true},
+		{name: "DeepOtherLeaf", selector: "**.title", path: "sections.0.subtitle", want: false},
+		{name: "AnyKey", selector: "meta.*", path: "meta.title", want: true},
+		{name: "FilterMatch", selector: `sections[_type=="hero"].heading`, path: "sections.0.heading", want: true},
+		{name: "FilterSingleQuotes", selector: `sections[_type=='hero'].heading`, path: "sections.0.heading", want: true},
+		{name: "FilterNoMatch", selector: `sections[_type=="hero"].heading`, path: "sections.1.heading", want: false},
+		{name: "TooShort", selector: "meta.title", path: "meta", want: false},
+		{name: "TooLong", selector: "meta", path: "meta.title", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("%s: ParseSelector() returned an error: %v", tt.name, err)
+			}
+			if got := selector.Match(tt.path, selectorTestDocument); got != tt.want {
+				t.Errorf("%s: Match(%q) = %v, want %v", tt.name, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchInputElementExclusions(t *testing.T) {
+	txx := SanityDocumentTranslator{
+		InputElements: []InputElement{
+			{Path: "**.title"},
+			{Path: "!meta.title"},
+		},
+		Before: selectorTestDocument,
+	}
+
+	if _, ok := matchInputElement(&txx, "title"); !ok {
+		t.Errorf("matchInputElement(title) = false, want true")
+	}
+	if _, ok := matchInputElement(&txx, "meta.title"); ok {
+		t.Errorf("matchInputElement(meta.title) = true, want false")
+	}
+}
@@ -1,42 +1,104 @@
 package main
 
 import (
-	sanity "github.com/sanity-io/client-go"
+	"encoding/json"
 )
 
-type SanityClient struct {
-	Client *sanity.Client
-}
-
 // SanityTranslator holds the translation rules for Sanity documents.
 type SanityDocumentTranslator struct {
 	Id            string
-	FromLang      string        // Language to translate from
-	FromSlug      string        // Slug of the document to translate
-	ToLang        string        // Language to translate to
-	ToSlug        string        // Slug of the translated document
-	InputElements []string      // Elements to translate (e.g. text.000.children.000.text)
-	Fields        []SanityField // Fields to translate (e.g. text.1.children.1.text)
-	Before        string        // Document before any changes
-	After         string        // Document after any changes
+	FromLang      string                 // Language to translate from
+	FromSlug      string                 // Slug of the document to translate
+	ToLang        string                 // Language to translate to
+	ToSlug        string                 // Slug of the translated document
+	Targets       []Target               // Languages to translate to in one request, instead of ToLang and ToSlug
+	Concurrency   int                    // Target languages translated at the same time, defaults to DefaultTargetConcurrency
+	Dataset       string                 // Sanity dataset, defaults to the configured one
+	GlossaryID    string                 // Glossary to apply with a single target language, defaults to the one stored for the language pair
+	Glossaries    map[string]string      // Glossary per target language, the others default to the one stored for their pair
+	Options       TranslationOptions     // Provider options applied to every field
+	PortableText  bool                   // Translate Portable Text blocks as a whole instead of span by span
+	LeafRules     LeafRules              // Overrides of the rules skipping system fields, URLs, emails, colors and slugs
+	FromDraft     bool                   // Translate the draft of the source document when there is one
+	Draft         string                 // Write into drafts.<id>: "replace" the draft, or "patch" the translated fields of an existing one
+	Merge         bool                   // Keep the existing target document and only overwrite fields whose source changed
+	Incremental   bool                   // Only translate the fields whose source changed since the last translation
+	Async         bool                   // Queue the translation as a job and return its id right away
+	DryRun        bool                   // Translate and return the result without writing to Sanity
+	ValidateWrite bool                   // With DryRun, submit the mutations to Sanity with dryRun=true
+	InputElements []InputElement         // Elements to translate (e.g. text.000.children.000.text)
+	Fields        []SanityField          // Fields to translate (e.g. text.1.children.1.text)
+	Before        string                 // Document before any changes
+	After         string                 // Document after any changes
+	target        string                 // Existing target document, loaded with Merge or Incremental
+	records       map[string]FieldRecord // _translationMeta records of the target document, by record path
+	copied        map[string]bool        // Paths of target copied from the source document by alignTarget
+	hooks         TranslationHooks
+}
+
+// InputElement selects the paths to translate, optionally overriding the request Options.
+// In JSON it is either a plain selector string or an object {"Path": ..., "Options": {...}}.
+type InputElement struct {
+	Path     string // Selector, see ParseSelector
+	Options  TranslationOptions
+	selector *Selector
+}
+
+func (e *InputElement) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		e.Path = path
+		return nil
+	}
+	type inputElement InputElement
+	return json.Unmarshal(data, (*inputElement)(e))
 }
 
+// Kinds of SanityField
+const (
+	FieldText          = ""             // Plain string value
+	FieldPortableBlock = "portableText" // Portable Text block serialized as XML
+)
+
 type SanityField struct {
 	Path              string
+	Kind              string // FieldText or FieldPortableBlock
 	OriginalContent   string
 	TranslatedContent string
+	Options           TranslationOptions // Options used to translate the field
+	SkipReason        string             // Why the field is not written, empty when it is
 }
 
 type SanityFieldTranslator struct {
 	Id            string
-	FromLang      string         // Language to translate from
-	FromSlug      string         // Slug of the document to translate
-	ToSlugs       []string       // Slugs of the translated documents
-	Before        string         // Document before any changes
-	MappingFields []MappingField // Mapping fields between JSON and Sanity
+	FromLang      string             // Language to translate from
+	FromSlug      string             // Slug of the document to translate
+	ToSlugs       []string           // Slugs of the translated documents
+	Dataset       string             // Sanity dataset, defaults to the configured one
+	GlossaryID    string             // Glossary to apply when every slug of ToSlugs has the same language
+	Glossaries    map[string]string  // Glossary per target language, the others default to the one stored for their pair
+	Options       TranslationOptions // Provider options applied to every field
+	LeafRules     LeafRules          // Overrides of the rules skipping system fields, URLs, emails, colors and slugs
+	Before        string             // Document before any changes
+	MappingFields []MappingField     // Mapping fields between JSON and Sanity
 }
 
 type MappingField struct {
 	JsonPath   string
 	SanityPath string
 }
+
+type GlossaryRequest struct {
+	Name          string
+	SourceLang    string
+	TargetLang    string
+	Entries       string // Term pairs, one per line
+	EntriesFormat string // "csv" or "tsv", defaults to "tsv"
+	Default       bool   // Use as default glossary for the language pair
+}
+
+type GlossaryDefaultRequest struct {
+	SourceLang string
+	TargetLang string
+	GlossaryID string // Empty to remove the default
+}
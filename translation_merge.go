@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// translationMetaField is the hidden field of translated documents recording,
+// for each translated path, the source text and the translation last written.
+const translationMetaField = "_translationMeta"
+
+// FieldRecord is the entry of _translationMeta.fields for one path.
+type FieldRecord struct {
+	Key         string `json:"_key"`
+	Path        string `json:"path"`
+	Source      string `json:"source"`      // Hash of the source text translated
+	Translation string `json:"translation"` // Hash of the translation written
+}
+
+// contentHash returns the hex SHA-256 of text.
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// readFieldRecords returns the _translationMeta records of document by path.
+func readFieldRecords(document string) map[string]FieldRecord {
+	records := map[string]FieldRecord{}
+	for _, record := range gjson.Get(document, translationMetaField+".fields").Array() {
+		path := record.Get("path").String()
+		records[path] = FieldRecord{
+			Key:         record.Get("_key").String(),
+			Path:        path,
+			Source:      record.Get("source").String(),
+			Translation: record.Get("translation").String(),
+		}
+	}
+	return records
+}
+
+// fieldContent returns the text of field as found in document, serializing
+// Portable Text blocks the same way they are sent for translation.
+func fieldContent(field SanityField, document string) (string, bool) {
+	if field.Kind == FieldPortableBlock {
+		children, ok := gjson.Get(document, field.Path+".children").Value().([]interface{})
+		if !ok {
+			return "", false
+		}
+		return SerializeBlock(children), true
+	}
+	value := gjson.Get(document, field.Path)
+	return value.String(), value.Exists() && value.String() != ""
+}
+
+// recordPath returns the path of document identifying the same item when
+// arrays are reordered: array indexes become [_key=="..."] when the item has
+// a _key (e.g. text.1.children.0.text is text[_key=="a1"].children[_key=="s1"].text).
+func recordPath(document, path string) string {
+	record, prefix := "", ""
+	for _, segment := range strings.Split(path, ".") {
+		parent := prefix
+		prefix = joinPath(prefix, segment)
+		if parent != "" && gjson.Get(document, parent).IsArray() {
+			if key := gjson.Get(document, prefix+"._key").String(); key != "" {
+				record += fmt.Sprintf("[_key==%q]", key)
+				continue
+			}
+		}
+		record = joinPath(record, segment)
+	}
+	return record
+}
+
+func joinPath(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
+
+// alignTarget reorders the arrays of target to match source, so that the
+// paths of the source fields point to the same items in both documents.
+// Items with a _key are paired by key: items missing from target are copied
+// whole from source and items removed from source are dropped. The spans of
+// a Portable Text block found in target are kept as they are. Returns the
+// aligned target and the paths copied from source.
+func alignTarget(source, target string) (string, map[string]bool, error) {
+	var sourceValue, targetValue interface{}
+	if err := decodeJSON(source, &sourceValue); err != nil {
+		return "", nil, err
+	}
+	if err := decodeJSON(target, &targetValue); err != nil {
+		return "", nil, err
+	}
+	copied := map[string]bool{}
+	aligned, err := json.Marshal(alignValue(sourceValue, targetValue, "", copied))
+	if err != nil {
+		return "", nil, err
+	}
+	return string(aligned), copied, nil
+}
+
+func decodeJSON(document string, value interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(document))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+func alignValue(source, target interface{}, path string, copied map[string]bool) interface{} {
+	switch sourceValue := source.(type) {
+	case map[string]interface{}:
+		targetValue, ok := target.(map[string]interface{})
+		if !ok {
+			copied[path] = true
+			return source
+		}
+		aligned := map[string]interface{}{}
+		for key, value := range targetValue {
+			aligned[key] = value
+		}
+		for key, value := range sourceValue {
+			current, exists := targetValue[key]
+			switch {
+			case !exists || current == nil:
+				copied[joinPath(path, key)] = true
+				aligned[key] = value
+			case key == "children" && isPortableTextBlock(sourceValue):
+			default:
+				aligned[key] = alignValue(value, current, joinPath(path, key), copied)
+			}
+		}
+		return aligned
+	case []interface{}:
+		targetValue, ok := target.([]interface{})
+		keys := itemKeys(sourceValue)
+		targetKeys := itemKeys(targetValue)
+		if !ok || (keys == nil && len(sourceValue) != len(targetValue)) {
+			copied[path] = true
+			return source
+		}
+		byKey := map[string]interface{}{}
+		for i, key := range targetKeys {
+			byKey[key] = targetValue[i]
+		}
+		aligned := make([]interface{}, len(sourceValue))
+		for i, value := range sourceValue {
+			itemPath := joinPath(path, fmt.Sprint(i))
+			current := interface{}(nil)
+			if keys == nil {
+				current = targetValue[i]
+			} else if item, exists := byKey[keys[i]]; exists {
+				current = item
+			}
+			if current == nil {
+				copied[itemPath] = true
+				aligned[i] = value
+				continue
+			}
+			aligned[i] = alignValue(value, current, itemPath, copied)
+		}
+		return aligned
+	default:
+		return target
+	}
+}
+
+// itemKeys returns the _key of every item of items, or nil when one has none.
+func itemKeys(items []interface{}) []string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		object, _ := item.(map[string]interface{})
+		key, _ := object["_key"].(string)
+		if key == "" {
+			return nil
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// LoadTargetDocument fetches the current target document and its _translationMeta
+// records, used to skip unchanged fields and to merge with the target.
+// When the target does not exist yet both stay empty.
+func LoadTargetDocument(txx *SanityDocumentTranslator) error {
+	targetID := gjson.Get(txx.After, "_id").String()
+	response, err := sanityFor(txx.Dataset).WithoutCDN().Query(
+		`*[_id == $id][0]`,
+		map[string]interface{}{"id": targetID},
+	)
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error extracting target document from Sanity:", targetID)
+		return err
+	}
+	target := gjson.Get(response, "result")
+	if !target.IsObject() {
+		fmt.Fprintf(logOutput, "No target document %s, translating everything\n", targetID)
+		return nil
+	}
+	aligned, copied, err := alignTarget(txx.Before, target.Raw)
+	if err != nil {
+		return err
+	}
+	txx.target = aligned
+	txx.copied = copied
+	txx.records = readFieldRecords(target.Raw)
+	return nil
+}
+
+// targetContent returns the text of field in the target document, unless
+// the target had no such item and it was copied from the source.
+func targetContent(txx *SanityDocumentTranslator, field SanityField) (string, bool) {
+	if copiedFromSource(txx, field.Path) {
+		return "", false
+	}
+	return fieldContent(field, txx.target)
+}
+
+// copiedFromSource reports whether path or one of its parents was missing
+// from the target document.
+func copiedFromSource(txx *SanityDocumentTranslator, path string) bool {
+	prefix := ""
+	for _, segment := range strings.Split(path, ".") {
+		prefix = joinPath(prefix, segment)
+		if txx.copied[prefix] {
+			return true
+		}
+	}
+	return false
+}
+
+// unchangedSource reports whether field was translated before from the same
+// source text and the target document still has it
+func unchangedSource(txx *SanityDocumentTranslator, field SanityField) bool {
+	record, ok := txx.records[recordPath(txx.Before, field.Path)]
+	if !ok || record.Source != contentHash(field.OriginalContent) {
+		return false
+	}
+	_, exists := targetContent(txx, field)
+	return exists
+}
+
+// keepTargetValue copies the value of a skipped field from the target document into After
+func keepTargetValue(txx *SanityDocumentTranslator, field SanityField) (string, error) {
+	path := field.Path
+	if field.Kind == FieldPortableBlock {
+		path += ".children"
+	}
+	value := gjson.Get(txx.target, path)
+	if txx.target == "" || !value.Exists() || copiedFromSource(txx, field.Path) {
+		return txx.After, nil
+	}
+	return sjson.SetRaw(txx.After, path, value.Raw)
+}
+
+// MergeWithTarget replaces After with the existing target document, aligned
+// with the source by alignTarget and keeping its target-only fields, and marks
+// the fields that must not be overwritten: fields edited by a translator since
+// the last translation and fields with no translation record. Fields of items
+// the target lacks are translated. Call LoadTargetDocument first; when the
+// target does not exist nothing changes.
+func MergeWithTarget(txx *SanityDocumentTranslator) error {
+	if txx.target == "" {
+		return nil
+	}
+	txx.After = txx.target
+	if err := EvolveSanityResponse(txx); err != nil {
+		return err
+	}
+
+	for i := range txx.Fields {
+		field := &txx.Fields[i]
+		if field.SkipReason != "" || copiedFromSource(txx, field.Path) {
+			continue
+		}
+		current, exists := targetContent(txx, *field)
+		record, recorded := txx.records[recordPath(txx.Before, field.Path)]
+		switch {
+		case !recorded && exists:
+			field.SkipReason = "no previous translation recorded, keeping the target value"
+		case !recorded:
+		case !exists || contentHash(current) != record.Translation:
+			field.SkipReason = "edited in the target document since the last translation"
+		}
+	}
+	return nil
+}
+
+// RecordTranslationMeta writes _translationMeta into After: the fields written now
+// get a new record, the records of the skipped fields are kept and skipped fields
+// with no record get one for the value kept from the target.
+func RecordTranslationMeta(txx *SanityDocumentTranslator) (err error) {
+	records := []FieldRecord{}
+	seen := map[string]bool{}
+	for _, field := range txx.Fields {
+		path := recordPath(txx.Before, field.Path)
+		seen[path] = true
+		if field.SkipReason != "" {
+			if record, ok := txx.records[path]; ok {
+				records = append(records, record)
+			} else if kept, exists := targetContent(txx, field); exists {
+				// Targets written before _translationMeta existed: the kept value becomes
+				// the baseline, so that the next merge can tell whether it was edited
+				records = append(records, FieldRecord{
+					Key:         contentHash(path)[:12],
+					Path:        path,
+					Source:      contentHash(field.OriginalContent),
+					Translation: contentHash(kept),
+				})
+			}
+			continue
+		}
+		written, _ := fieldContent(field, txx.After)
+		records = append(records, FieldRecord{
+			Key:         contentHash(path)[:12],
+			Path:        path,
+			Source:      contentHash(field.OriginalContent),
+			Translation: contentHash(written),
+		})
+	}
+	// Keep the records of paths not selected by this request
+	for path, record := range txx.records {
+		if !seen[path] {
+			records = append(records, record)
+		}
+	}
+
+	txx.After, err = sjson.Set(
+		txx.After,
+		translationMetaField,
+		map[string]interface{}{
+			"fromLang": txx.FromLang,
+			"fields":   records,
+		},
+	)
+	return err
+}
+
+// SkippedFields lists the paths that were not written and why.
+func SkippedFields(txx *SanityDocumentTranslator) []map[string]string {
+	skipped := []map[string]string{}
+	for _, field := range txx.Fields {
+		if field.SkipReason != "" {
+			skipped = append(skipped, map[string]string{
+				"path":   field.Path,
+				"reason": field.SkipReason,
+			})
+		}
+	}
+	return skipped
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSanityTranslateDocumentMerge(t *testing.T) {
+	record := func(path, source, translation string) string {
+		return fmt.Sprintf(
+			`{"_key": "%s", "path": "%s", "source": "%s", "translation": "%s"}`,
+			path, path, contentHash(source), contentHash(translation),
+		)
+	}
+	target := `{
+		"_id": "doc_it", "_type": "test", "_rev": "r1", "language": "it", "slug": {"current": "/it/doc"},
+		"title": "IT: Old title", "intro": "IT: Intro", "body": "Corpo corretto", "caption": "Didascalia",
+		"image": {"asset": {"_ref": "image-it"}},
+		"_translationMeta": {"fromLang": "en", "fields": [` +
+		record("title", "Old title", "IT: Old title") + `,` +
+		record("intro", "Intro", "IT: Intro") + `,` +
+		record("body", "Old body", "IT: Old body") + `]}
+	}`
+
+	tests := []struct {
+		name        string
+		target      string
+		wantValues  map[string]string
+		wantSkipped map[string]string
+	}{
+		{
+			name:   "MergeWithExistingTarget",
+			target: target,
+			wantValues: map[string]string{
+				"title":            "IT: Title",
+				"intro":            "IT: Intro",
+				"body":             "Corpo corretto",
+				"caption":          "Didascalia",
+				"subtitle":         "IT: Subtitle",
+				"image.asset._ref": "image-it",
+			},
+			wantSkipped: map[string]string{
+				"intro":   "source unchanged",
+				"body":    "edited in the target document",
+				"caption": "no previous translation recorded",
+			},
+		},
+		{
+			name:   "NoTarget",
+			target: `null`,
+			wantValues: map[string]string{
+				"title":   "IT: Title",
+				"body":    "IT: Body",
+				"caption": "IT: Caption",
+			},
+			wantSkipped: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeSanity(t, func(query string) string {
+				switch {
+				case strings.Contains(query, "translation.metadata"):
+					return `null`
+				case strings.Contains(query, "_id == $id"):
+					return tt.target
+				}
+				return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"},
+					"title": "Title", "intro": "Intro", "body": "Body", "caption": "Caption", "subtitle": "Subtitle"}`
+			})
+			w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc",
+				"InputElements": ["title", "intro", "body", "caption", "subtitle"], "Merge": true}`)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %v, got %v: %s", tt.name, http.StatusOK, w.Code, w.Body.String())
+			}
+			if len(fake.mutations) != 1 {
+				t.Fatalf("%s: sent %d mutate requests, want 1", tt.name, len(fake.mutations))
+			}
+
+			document := gjson.Get(fake.mutations[0], "mutations.0.createOrReplace")
+			for path, want := range tt.wantValues {
+				if got := document.Get(path).String(); got != want {
+					t.Errorf("%s: %s = %q, want %q", tt.name, path, got, want)
+				}
+			}
+			if got := document.Get(`_translationMeta.fields.#(path=="title").source`).String(); got != contentHash("Title") {
+				t.Errorf("%s: title source hash = %q, want the hash of the new source", tt.name, got)
+			}
+			if got := document.Get(`_translationMeta.fields.#(path=="body").source`).String(); tt.target != "null" && got != contentHash("Old body") {
+				t.Errorf("%s: body record should be kept while the field is skipped, got source %q", tt.name, got)
+			}
+
+			skipped := gjson.Get(w.Body.String(), "skipped").Array()
+			if len(skipped) != len(tt.wantSkipped) {
+				t.Fatalf("%s: skipped = %v, want %v", tt.name, skipped, tt.wantSkipped)
+			}
+			for _, entry := range skipped {
+				want, ok := tt.wantSkipped[entry.Get("path").String()]
+				if !ok || !strings.HasPrefix(entry.Get("reason").String(), want) {
+					t.Errorf("%s: unexpected skipped entry %s", tt.name, entry.Raw)
+				}
+			}
+		})
+	}
+}
+
+func TestSanityTranslateDocumentIncremental(t *testing.T) {
+	record := func(path, source, translation string) string {
+		return fmt.Sprintf(
+			`{"_key": "%s", "path": "%s", "source": "%s", "translation": "%s"}`,
+			path, path, contentHash(source), contentHash(translation),
+		)
+	}
+	fake := useFakeSanity(t, func(query string) string {
+		switch {
+		case strings.Contains(query, "translation.metadata"):
+			return `null`
+		case strings.Contains(query, "_id == $id"):
+			return `{"_id": "doc_it", "_type": "test", "title": "IT: Old title", "intro": "Introduzione", "body": "Corpo corretto",
+				"_translationMeta": {"fromLang": "en", "fields": [` +
+				record("title", "Old title", "IT: Old title") + `,` +
+				record("intro", "Intro", "Introduzione") + `,` +
+				record("body", "Body", "IT: Body") + `]}}`
+		}
+		return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "image": {"asset": {"_ref": "image-en"}},
+			"title": "Title", "intro": "Intro", "body": "Body", "caption": "Caption"}`
+	})
+	w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc",
+		"InputElements": ["title", "intro", "body", "caption"], "Incremental": true}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(fake.mutations) != 1 {
+		t.Fatalf("sent %d mutate requests, want 1", len(fake.mutations))
+	}
+
+	document := gjson.Get(fake.mutations[0], "mutations.0.createOrReplace")
+	for path, want := range map[string]string{
+		"title":            "IT: Title",      // Source changed
+		"intro":            "Introduzione",   // Source unchanged
+		"body":             "Corpo corretto", // Source unchanged, target edited
+		"caption":          "IT: Caption",    // Never translated before
+		"image.asset._ref": "image-en",
+	} {
+		if got := document.Get(path).String(); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if got := len(document.Get("_translationMeta.fields").Array()); got != 4 {
+		t.Errorf("_translationMeta has %d records, want 4: %s", got, document.Get("_translationMeta").Raw)
+	}
+
+	skipped := gjson.Get(w.Body.String(), "skipped.#.path").Array()
+	if len(skipped) != 2 || skipped[0].String() == skipped[1].String() ||
+		!strings.Contains("intro body", skipped[0].String()) || !strings.Contains("intro body", skipped[1].String()) {
+		t.Errorf("skipped = %v, want intro and body", skipped)
+	}
+}
+
+func TestSanityTranslateDocumentMergeUnrecordedTarget(t *testing.T) {
+	target := `{"_id": "doc_it", "_type": "test", "_rev": "r1", "language": "it", "slug": {"current": "/it/doc"},
+		"title": "Titolo", "caption": "Didascalia"}`
+	source := `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title", "caption": "Caption"}`
+	fake := useFakeSanity(t, func(query string) string {
+		switch {
+		case strings.Contains(query, "translation.metadata"):
+			return `null`
+		case strings.Contains(query, "_id == $id"):
+			return target
+		}
+		return source
+	})
+	request := `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc",
+		"InputElements": ["title", "caption"], "Merge": true}`
+
+	// The first merge keeps the values and records them as the baseline
+	w := serveTranslateDocument(t, request)
+	if w.Code != http.StatusOK || len(fake.mutations) != 1 {
+		t.Fatalf("first merge: status %v, %d mutate requests: %s", w.Code, len(fake.mutations), w.Body.String())
+	}
+	if got := len(gjson.Get(w.Body.String(), "skipped").Array()); got != 2 {
+		t.Errorf("first merge skipped %d fields, want 2", got)
+	}
+	document := gjson.Get(fake.mutations[0], "mutations.0.createOrReplace")
+	if got := document.Get(`_translationMeta.fields.#(path=="caption").translation`).String(); got != contentHash("Didascalia") {
+		t.Errorf("caption baseline = %q, want the hash of the kept value", got)
+	}
+
+	// The second merge, with the source changed, overwrites the unedited field
+	target = document.Raw
+	source = `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title", "caption": "New caption"}`
+	w = serveTranslateDocument(t, request)
+	if w.Code != http.StatusOK || len(fake.mutations) != 2 {
+		t.Fatalf("second merge: status %v, %d mutate requests: %s", w.Code, len(fake.mutations), w.Body.String())
+	}
+	document = gjson.Get(fake.mutations[1], "mutations.0.createOrReplace")
+	if got := document.Get("caption").String(); got != "IT: New caption" {
+		t.Errorf("caption = %q, want %q", got, "IT: New caption")
+	}
+	if got := document.Get("title").String(); got != "Titolo" {
+		t.Errorf("title = %q, want %q: its source did not change", got, "Titolo")
+	}
+}
+
+func TestSanityTranslateDocumentMergeBlocks(t *testing.T) {
+	block := func(key, text string) string {
+		return fmt.Sprintf(`{"_key": "%s", "_type": "block", "style": "normal", "markDefs": [],
+			"children": [{"_key": "%s1", "_type": "span", "marks": [], "text": "%s"}]}`, key, key, text)
+	}
+	record := func(key, source, translation string) string {
+		path := fmt.Sprintf(`text[_key==\"%s\"].children[_key==\"%s1\"].text`, key, key)
+		return fmt.Sprintf(
+			`{"_key": "%s", "path": "%s", "source": "%s", "translation": "%s"}`,
+			key, path, contentHash(source), contentHash(translation),
+		)
+	}
+
+	tests := []struct {
+		name       string
+		source     []string
+		target     []string
+		records    []string
+		wantKeys   []string
+		wantTexts  []string
+		wantCopied bool // The first block is written whole from the source
+	}{
+		{
+			name:       "InsertedBlock",
+			source:     []string{block("n", "New"), block("a", "One")},
+			target:     []string{block("a", "Uno")},
+			records:    []string{record("a", "One", "Uno")},
+			wantKeys:   []string{"n", "a"},
+			wantTexts:  []string{"IT: New", "Uno"},
+			wantCopied: true,
+		},
+		{
+			name:      "RemovedBlock",
+			source:    []string{block("a", "One")},
+			target:    []string{block("x", "Vecchio"), block("a", "Uno")},
+			records:   []string{record("x", "Old", "Vecchio"), record("a", "One", "Uno")},
+			wantKeys:  []string{"a"},
+			wantTexts: []string{"Uno"},
+		},
+		{
+			name:      "ReorderedBlocks",
+			source:    []string{block("b", "Two"), block("a", "One changed")},
+			target:    []string{block("a", "Uno"), block("b", "Due")},
+			records:   []string{record("a", "One", "Uno"), record("b", "Two", "Due")},
+			wantKeys:  []string{"b", "a"},
+			wantTexts: []string{"Due", "IT: One changed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeSanity(t, func(query string) string {
+				switch {
+				case strings.Contains(query, "translation.metadata"):
+					return `null`
+				case strings.Contains(query, "_id == $id"):
+					return `{"_id": "doc_it", "_type": "test", "_rev": "r1", "language": "it", "slug": {"current": "/it/doc"},
+						"text": [` + strings.Join(tt.target, ",") + `],
+						"_translationMeta": {"fromLang": "en", "fields": [` + strings.Join(tt.records, ",") + `]}}`
+				}
+				return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "text": [` + strings.Join(tt.source, ",") + `]}`
+			})
+			w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc",
+				"InputElements": ["text[*].children[*].text"], "Merge": true}`)
+
+			if w.Code != http.StatusOK || len(fake.mutations) != 1 {
+				t.Fatalf("%s: status %v, %d mutate requests: %s", tt.name, w.Code, len(fake.mutations), w.Body.String())
+			}
+			document := gjson.Get(fake.mutations[0], "mutations.0.createOrReplace")
+			blocks := document.Get("text").Array()
+			if len(blocks) != len(tt.wantKeys) {
+				t.Fatalf("%s: text has %d blocks, want %d: %s", tt.name, len(blocks), len(tt.wantKeys), document.Get("text").Raw)
+			}
+			for i, block := range blocks {
+				if got := block.Get("_key").String(); got != tt.wantKeys[i] {
+					t.Errorf("%s: text.%d._key = %q, want %q", tt.name, i, got, tt.wantKeys[i])
+				}
+				if got := block.Get("children.0.text").String(); got != tt.wantTexts[i] {
+					t.Errorf("%s: text.%d text = %q, want %q", tt.name, i, got, tt.wantTexts[i])
+				}
+			}
+			if tt.wantCopied {
+				first := blocks[0]
+				if first.Get("_type").String() != "block" || !first.Get("markDefs").IsArray() || first.Get("children.0._key").String() != "n1" {
+					t.Errorf("%s: inserted block was not copied whole: %s", tt.name, first.Raw)
+				}
+			}
+			records := document.Get("_translationMeta.fields").Array()
+			for i, key := range tt.wantKeys {
+				path := fmt.Sprintf(`text[_key=="%s"].children[_key=="%s1"].text`, key, key)
+				found := false
+				for _, record := range records {
+					if record.Get("path").String() == path {
+						found = record.Get("translation").String() == contentHash(tt.wantTexts[i])
+					}
+				}
+				if !found {
+					t.Errorf("%s: no record of %s for %q", tt.name, path, tt.wantTexts[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// fakeSanity serves canned query results and records the mutate request bodies.
+type fakeSanity struct {
+	server        *httptest.Server
+	queries       func(query string) string // Returns the raw JSON result of a query
+	params        url.Values                // Query string of the last query, with its $parameters
+	mutations     []string
+	mutateQueries []string // Query strings of the mutate requests (e.g. dryRun=true)
+	conflicts     int      // Number of mutate requests to reject with 409 before succeeding
+}
+
+// useFakeSanity points ActiveSanity to a fake server for the duration of the test.
+func useFakeSanity(t *testing.T, queries func(query string) string) *fakeSanity {
+	fake := &fakeSanity{queries: queries}
+	fake.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/query/"):
+			fake.params = r.URL.Query()
+			w.Write([]byte(`{"result": ` + fake.queries(r.URL.Query().Get("query")) + `}`))
+		case strings.HasPrefix(r.URL.Path, "/mutate/"):
+			body, _ := io.ReadAll(r.Body)
+			fake.mutations = append(fake.mutations, string(body))
+			fake.mutateQueries = append(fake.mutateQueries, r.URL.RawQuery)
+			if fake.conflicts > 0 {
+				fake.conflicts--
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error": {"description": "Document has been modified"}}`))
+				return
+			}
+			w.Write([]byte(`{"transactionId": "tx-1", "results": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	originalSanity := ActiveSanity
+	ActiveSanity = &SanityClient{Dataset: "production", APIURL: fake.server.URL}
+	t.Cleanup(func() {
+		ActiveSanity = originalSanity
+		fake.server.Close()
+	})
+	return fake
+}
+
+func testMetadataTranslator() *SanityDocumentTranslator {
+	return &SanityDocumentTranslator{
+		Id:       "doc",
+		FromLang: "en",
+		FromSlug: "/en/doc",
+		ToLang:   "it",
+		ToSlug:   "/it/doc",
+		Before:   `{"_id": "doc", "_type": "test"}`,
+		After:    `{"_id": "doc_it", "_type": "test"}`,
+	}
+}
+
+func TestManageTranslationMetadataCreatesDocument(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		return `null`
+	})
+
+	if err := ManageTranslationMetadata(testMetadataTranslator()); err != nil {
+		t.Fatalf("ManageTranslationMetadata() returned an error: %v", err)
+	}
+	if len(fake.mutations) != 1 {
+		t.Fatalf("ManageTranslationMetadata() sent %d mutations, want 1", len(fake.mutations))
+	}
+
+	metadata := gjson.Get(fake.mutations[0], "mutations.0.create")
+	if metadata.Get("_id").String() != "doc_base" || metadata.Get("_type").String() != "translation.metadata" {
+		t.Errorf("unexpected metadata document: %s", metadata.Raw)
+	}
+	if metadata.Get("schemaTypes.0").String() != "test" {
+		t.Errorf("schemaTypes = %s, want [test]", metadata.Get("schemaTypes").Raw)
+	}
+	if metadata.Get("translations.0._key").String() != "en" || metadata.Get("translations.0.value._ref").String() != "doc" {
+		t.Errorf("source translation = %s", metadata.Get("translations.0").Raw)
+	}
+	if metadata.Get("translations.1._key").String() != "it" || metadata.Get("translations.1.value._ref").String() != "doc_it" {
+		t.Errorf("target translation = %s", metadata.Get("translations.1").Raw)
+	}
+}
+
+func TestManageTranslationMetadataExistingBaseDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		wantErr bool
+		check   func(t *testing.T, patch gjson.Result)
+	}{
+		{
+			name: "MetadataNotReferencingSource",
+			base: `{"_id": "doc_base", "_type": "translation.metadata", "_rev": "rev-1",
+				"translations": [{"_key": "de", "value": {"_ref": "doc_de", "_type": "reference"}}]}`,
+			check: func(t *testing.T, patch gjson.Result) {
+				if patch.Get("id").String() != "doc_base" || patch.Get("ifRevisionID").String() != "rev-1" {
+					t.Errorf("unexpected patch: %s", patch.Raw)
+				}
+				if patch.Get("insert.items.#._key").String() != `["en","it"]` || patch.Get("insert.items.0.value._ref").String() != "doc" {
+					t.Errorf("source and target should be inserted: %s", patch.Get("insert").Raw)
+				}
+			},
+		},
+		{
+			name:    "OtherDocument",
+			base:    `{"_id": "doc_base", "_type": "page", "_rev": "rev-1"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake *fakeSanity
+			fake = useFakeSanity(t, func(query string) string {
+				if fake.params.Get("$baseId") != `"doc_base"` {
+					return `null`
+				}
+				return tt.base
+			})
+
+			err := ManageTranslationMetadata(testMetadataTranslator())
+			if tt.wantErr {
+				if err == nil || len(fake.mutations) != 0 {
+					t.Errorf("%s: ManageTranslationMetadata() = %v after %d mutations, want an error and none", tt.name, err, len(fake.mutations))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: ManageTranslationMetadata() returned an error: %v", tt.name, err)
+			}
+			if len(fake.mutations) != 1 {
+				t.Fatalf("%s: sent %d mutations, want 1", tt.name, len(fake.mutations))
+			}
+			tt.check(t, gjson.Get(fake.mutations[0], "mutations.0.patch"))
+		})
+	}
+}
+
+func TestManageTranslationMetadataFillsEmptyDocument(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		return `{"_id": "existing", "_type": "translation.metadata", "_rev": "rev-1", "translations": []}`
+	})
+
+	if err := ManageTranslationMetadata(testMetadataTranslator()); err != nil {
+		t.Fatalf("ManageTranslationMetadata() returned an error: %v", err)
+	}
+	if len(fake.mutations) != 1 {
+		t.Fatalf("ManageTranslationMetadata() sent %d mutations, want 1", len(fake.mutations))
+	}
+
+	patch := gjson.Get(fake.mutations[0], "mutations.0.patch")
+	if patch.Get("id").String() != "existing" || patch.Get("ifRevisionID").String() != "rev-1" || len(patch.Get("set.translations").Array()) != 2 {
+		t.Errorf("unexpected patch: %s", patch.Raw)
+	}
+}
+
+const existingMetadata = `{
+	"_id": "doc_base",
+	"_rev": "rev-2",
+	"translations": [
+		{"_key": "en", "value": {"_ref": "doc", "_type": "reference"}},
+		{"_key": "it", "value": {"_ref": "old_it", "_type": "reference"}}
+	]
+}`
+
+func TestManageTranslationMetadataUpsert(t *testing.T) {
+	tests := []struct {
+		name       string
+		toLang     string
+		targetID   string
+		wantSet    string
+		wantInsert bool
+	}{
+		{name: "ReplaceExisting", toLang: "it", targetID: "doc_it", wantSet: `translations[_key=="it"].value`},
+		{name: "InsertMissing", toLang: "fr", targetID: "doc_fr", wantInsert: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeSanity(t, func(query string) string {
+				return existingMetadata
+			})
+
+			txx := testMetadataTranslator()
+			txx.ToLang = tt.toLang
+			txx.After = `{"_id": "` + tt.targetID + `"}`
+			if err := ManageTranslationMetadata(txx); err != nil {
+				t.Fatalf("%s: ManageTranslationMetadata() returned an error: %v", tt.name, err)
+			}
+
+			patch := gjson.Get(fake.mutations[0], "mutations.0.patch")
+			if patch.Get("ifRevisionID").String() != "rev-2" {
+				t.Errorf("%s: ifRevisionID = %q, want rev-2", tt.name, patch.Get("ifRevisionID").String())
+			}
+			if tt.wantInsert {
+				if patch.Get("insert.items.0._key").String() != tt.toLang || patch.Get("insert.items.0.value._ref").String() != tt.targetID {
+					t.Errorf("%s: unexpected insert: %s", tt.name, patch.Raw)
+				}
+				return
+			}
+			if patch.Get("insert").Exists() {
+				t.Errorf("%s: existing language must not be inserted again: %s", tt.name, patch.Raw)
+			}
+			set := patch.Get("set").Map()
+			if set[tt.wantSet].Get("_ref").String() != tt.targetID {
+				t.Errorf("%s: unexpected set: %s", tt.name, patch.Get("set").Raw)
+			}
+		})
+	}
+}
+
+func TestManageTranslationMetadataRetriesOnConflict(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		return existingMetadata
+	})
+	fake.conflicts = 1
+
+	txx := testMetadataTranslator()
+	txx.ToLang = "fr"
+	if err := ManageTranslationMetadata(txx); err != nil {
+		t.Fatalf("ManageTranslationMetadata() returned an error: %v", err)
+	}
+	if len(fake.mutations) != 2 {
+		t.Errorf("ManageTranslationMetadata() sent %d mutations, want 2", len(fake.mutations))
+	}
+
+	fake.mutations = nil
+	fake.conflicts = metadataMaxAttempts
+	if err := ManageTranslationMetadata(txx); err == nil {
+		t.Errorf("ManageTranslationMetadata() expected an error after %d conflicts", metadataMaxAttempts)
+	}
+}
+
+func TestSanityTranslateDocumentSingleTransaction(t *testing.T) {
+	fake := useFakeSanity(t, func(query string) string {
+		if strings.Contains(query, "translation.metadata") {
+			return `null`
+		}
+		return `{"_id": "doc", "_type": "test", "language": "en", "slug": {"current": "/en/doc"}, "title": "Title"}`
+	})
+
+	w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc", "InputElements": ["title"]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := gjson.Get(w.Body.String(), "transactionId").String(); got != "tx-1" {
+		t.Errorf("transactionId = %q, want tx-1", got)
+	}
+	if len(fake.mutations) != 1 {
+		t.Fatalf("SanityTranslateDocument() sent %d mutate requests, want 1", len(fake.mutations))
+	}
+
+	mutations := gjson.Get(fake.mutations[0], "mutations")
+	if mutations.Get("0.createOrReplace._id").String() != "doc_it" || mutations.Get("0.createOrReplace.title").String() != "IT: Title" {
+		t.Errorf("unexpected document mutation: %s", mutations.Get("0").Raw)
+	}
+	if mutations.Get("1.create._id").String() != "doc_base" {
+		t.Errorf("unexpected metadata mutation: %s", mutations.Get("1").Raw)
+	}
+}
+
+func TestSanityTranslateDocumentDryRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		validateWrite bool
+		wantMutations int
+	}{
+		{name: "Preview", validateWrite: false, wantMutations: 0},
+		{name: "PreviewValidatedBySanity", validateWrite: true, wantMutations: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeSanity(t, func(query string) string {
+				if strings.Contains(query, "translation.metadata") {
+					return `null`
+				}
+				return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title", "intro": "Intro"}`
+			})
+			w := serveTranslateDocument(t, fmt.Sprintf(
+				`{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc", "InputElements": ["title"], "DryRun": true, "ValidateWrite": %t}`,
+				tt.validateWrite,
+			))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %v, got %v: %s", tt.name, http.StatusOK, w.Code, w.Body.String())
+			}
+			response := gjson.Parse(w.Body.String())
+			if response.Get("document.title").String() != "IT: Title" || response.Get("document.slug.current").String() != "/it/doc" {
+				t.Errorf("%s: unexpected document: %s", tt.name, response.Get("document").Raw)
+			}
+			if response.Get("fields.0.Path").String() != "title" || response.Get("fields.0.OriginalContent").String() != "Title" {
+				t.Errorf("%s: unexpected fields: %s", tt.name, response.Get("fields").Raw)
+			}
+			if len(fake.mutations) != tt.wantMutations {
+				t.Fatalf("%s: sent %d mutate requests, want %d", tt.name, len(fake.mutations), tt.wantMutations)
+			}
+			for _, query := range fake.mutateQueries {
+				if query != "dryRun=true" {
+					t.Errorf("%s: mutate query = %q, want dryRun=true", tt.name, query)
+				}
+			}
+		})
+	}
+}
+
+// useFakeTranslator makes ActiveTranslator prefix translations with "IT: " for the duration of the test.
+func useFakeTranslator(t *testing.T) *fakeTranslator {
+	fake := &fakeTranslator{prefix: "IT: "}
+	originalTranslator := ActiveTranslator
+	ActiveTranslator = fake
+	t.Cleanup(func() { ActiveTranslator = originalTranslator })
+	return fake
+}
+
+// serveTranslateDocument runs a /sanity_translate_document request through a test router.
+func serveTranslateDocument(t *testing.T, body string) *httptest.ResponseRecorder {
+	useFakeTranslator(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/sanity_translate_document", SanityTranslateDocument)
+
+	req := httptest.NewRequest("POST", "/sanity_translate_document", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSanityTranslateDocumentDrafts(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       string
+		existingDraft bool
+		wantSource    string // Substring of the query fetching the source document
+		check         func(t *testing.T, mutations gjson.Result)
+	}{
+		{
+			name:       "ReplaceDraft",
+			request:    `"Draft": "replace"`,
+			wantSource: `!(_id in path("drafts.**"))`,
+			check: func(t *testing.T, mutations gjson.Result) {
+				if mutations.Get("0.createOrReplace._id").String() != "drafts.doc_it" {
+					t.Errorf("unexpected document mutation: %s", mutations.Get("0").Raw)
+				}
+				target := mutations.Get("1.create.translations.1.value")
+				if target.Get("_ref").String() != "doc_it" || !target.Get("_weak").Bool() || target.Get("_strengthenOnPublish.type").String() != "test" {
+					t.Errorf("unexpected draft reference: %s", target.Raw)
+				}
+			},
+		},
+		{
+			name:          "PatchExistingDraft",
+			request:       `"Draft": "patch"`,
+			existingDraft: true,
+			wantSource:    `!(_id in path("drafts.**"))`,
+			check: func(t *testing.T, mutations gjson.Result) {
+				patch := mutations.Get("0.patch")
+				if patch.Get("id").String() != "drafts.doc_it" || patch.Get("set.title").String() != "IT: Title" {
+					t.Errorf("unexpected draft patch: %s", patch.Raw)
+				}
+				if patch.Get("set.intro").Exists() {
+					t.Errorf("untranslated fields must not be patched: %s", patch.Raw)
+				}
+			},
+		},
+		{
+			name:       "FromDraft",
+			request:    `"FromDraft": true`,
+			wantSource: `order(_id in path("drafts.**") desc)`,
+			check: func(t *testing.T, mutations gjson.Result) {
+				if mutations.Get("0.createOrReplace._id").String() != "doc_it" {
+					t.Errorf("unexpected document mutation: %s", mutations.Get("0").Raw)
+				}
+				if mutations.Get("1.create.translations.0.value._ref").String() != "doc" {
+					t.Errorf("source must be referenced by its published id: %s", mutations.Get("1").Raw)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sourceQuery string
+			fake := useFakeSanity(t, func(query string) string {
+				switch {
+				case strings.Contains(query, "translation.metadata"):
+					return `null`
+				case strings.Contains(query, "_id == $id"):
+					if tt.existingDraft {
+						return `{"_id": "drafts.doc_it"}`
+					}
+					return `null`
+				default:
+					sourceQuery = query
+					return `{"_id": "drafts.doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title", "intro": "Intro"}`
+				}
+			})
+
+			w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "ToLang": "it", "ToSlug": "/it/doc", "InputElements": ["title"], `+tt.request+`}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %v, got %v: %s", tt.name, http.StatusOK, w.Code, w.Body.String())
+			}
+			if !strings.Contains(sourceQuery, tt.wantSource) {
+				t.Errorf("%s: source query = %q, want it to contain %q", tt.name, sourceQuery, tt.wantSource)
+			}
+			if len(fake.mutations) != 1 {
+				t.Fatalf("%s: sent %d mutate requests, want 1", tt.name, len(fake.mutations))
+			}
+			tt.check(t, gjson.Get(fake.mutations[0], "mutations"))
+		})
+	}
+}
+
+func TestToSanityPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "title", want: "title"},
+		{path: "text.0.children.12.text", want: "text[0].children[12].text"},
+		{path: "testArray.1", want: "testArray[1]"},
+	}
+
+	for _, tt := range tests {
+		if got := toSanityPath(tt.path); got != tt.want {
+			t.Errorf("toSanityPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxSchemaDepth bounds the expansion of recursive schema types.
+const maxSchemaDepth = 10
+
+// TranslationSchema declares, for each document _type, the selectors of its translatable fields.
+type TranslationSchema struct {
+	Types map[string][]string
+}
+
+// ActiveSchema is the schema used when a request has no InputElements, nil when not configured.
+var ActiveSchema *TranslationSchema
+
+// LoadTranslationSchema reads a schema from path. YAML files map each _type to a list
+// of selectors; JSON files are Studio schema exports whose fields are discovered by type.
+func LoadTranslationSchema(path string) (*TranslationSchema, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading translation schema: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAMLSchema(content)
+	case ".json":
+		return ParseStudioSchema(content)
+	default:
+		return nil, fmt.Errorf("unsupported translation schema format: %s", path)
+	}
+}
+
+// ParseYAMLSchema parses a schema like:
+//
+//	page:
+//	  - title
+//	  - text[*].children[*].text
+func ParseYAMLSchema(content []byte) (*TranslationSchema, error) {
+	types := map[string][]string{}
+	if err := yaml.Unmarshal(content, &types); err != nil {
+		return nil, fmt.Errorf("error parsing translation schema: %w", err)
+	}
+	for schemaType, selectors := range types {
+		for _, selector := range selectors {
+			if _, err := ParseSelector(selector); err != nil {
+				return nil, fmt.Errorf("invalid selector for %s: %w", schemaType, err)
+			}
+		}
+	}
+	return &TranslationSchema{Types: types}, nil
+}
+
+// StudioSchemaType is a type or field definition as exported from Sanity Studio.
+type StudioSchemaType struct {
+	Name         string             `json:"name"`
+	Type         string             `json:"type"`
+	Fields       []StudioSchemaType `json:"fields"`
+	Of           []StudioSchemaType `json:"of"`
+	Hidden       bool               `json:"hidden"`
+	ReadOnly     bool               `json:"readOnly"`
+	Translatable *bool              `json:"translatable"` // Explicit opt-in or opt-out
+	Options      struct {
+		Translatable *bool `json:"translatable"`
+	} `json:"options"`
+}
+
+// isTranslatable applies the explicit flag, or skips hidden and read-only fields.
+func (f StudioSchemaType) isTranslatable() bool {
+	if f.Translatable != nil {
+		return *f.Translatable
+	}
+	if f.Options.Translatable != nil {
+		return *f.Options.Translatable
+	}
+	return !f.Hidden && !f.ReadOnly && !strings.HasPrefix(f.Name, "_")
+}
+
+// ParseStudioSchema discovers translatable fields from a Studio schema export:
+// string and text fields, arrays of them, Portable Text blocks, and the fields
+// of nested objects. Document types are the entries with type "document".
+func ParseStudioSchema(content []byte) (*TranslationSchema, error) {
+	var studioTypes []StudioSchemaType
+	if err := json.Unmarshal(content, &studioTypes); err != nil {
+		return nil, fmt.Errorf("error parsing Studio schema: %w", err)
+	}
+
+	named := map[string]StudioSchemaType{}
+	for _, studioType := range studioTypes {
+		named[studioType.Name] = studioType
+	}
+
+	schema := &TranslationSchema{Types: map[string][]string{}}
+	for _, studioType := range studioTypes {
+		if studioType.Type != "document" {
+			continue
+		}
+		selectors := []string{}
+		for _, field := range studioType.Fields {
+			selectors = append(selectors, discoverField(field, field.Name, named, 0)...)
+		}
+		schema.Types[studioType.Name] = selectors
+	}
+	return schema, nil
+}
+
+// discoverField returns the selectors of the translatable values under field, found at path.
+func discoverField(field StudioSchemaType, path string, named map[string]StudioSchemaType, depth int) []string {
+	if depth > maxSchemaDepth || !field.isTranslatable() {
+		return nil
+	}
+
+	switch field.Type {
+	case "string", "text":
+		return []string{path}
+	case "block":
+		return []string{path + ".children[*].text"}
+	case "object", "document":
+		selectors := []string{}
+		for _, sub := range field.Fields {
+			selectors = append(selectors, discoverField(sub, path+"."+sub.Name, named, depth+1)...)
+		}
+		return selectors
+	case "array":
+		selectors := []string{}
+		for _, item := range field.Of {
+			switch {
+			case item.Type == "string" || item.Type == "text":
+				selectors = append(selectors, path+"[*]")
+			case item.Type == "block":
+				selectors = append(selectors, path+`[_type=="block"].children[*].text`)
+			case item.Type == "object":
+				filter := "[*]"
+				if item.Name != "" {
+					filter = fmt.Sprintf(`[_type=="%s"]`, item.Name)
+				}
+				for _, sub := range item.Fields {
+					selectors = append(selectors, discoverField(sub, path+filter+"."+sub.Name, named, depth+1)...)
+				}
+			default:
+				// Named object type defined elsewhere in the schema
+				if namedType, ok := named[item.Type]; ok {
+					prefix := fmt.Sprintf(`%s[_type=="%s"]`, path, item.Type)
+					for _, sub := range namedType.Fields {
+						selectors = append(selectors, discoverField(sub, prefix+"."+sub.Name, named, depth+1)...)
+					}
+				}
+			}
+		}
+		return selectors
+	default:
+		// Named object type used as a field
+		if namedType, ok := named[field.Type]; ok && namedType.Type == "object" {
+			selectors := []string{}
+			for _, sub := range namedType.Fields {
+				selectors = append(selectors, discoverField(sub, path+"."+sub.Name, named, depth+1)...)
+			}
+			return selectors
+		}
+		return nil
+	}
+}
+
+// InputElements returns the selectors declared for the given document _type.
+func (s *TranslationSchema) InputElements(documentType string) []InputElement {
+	if s == nil {
+		return nil
+	}
+	elements := []InputElement{}
+	for _, selector := range s.Types[documentType] {
+		elements = append(elements, InputElement{Path: selector})
+	}
+	return elements
+}
+
+// DocumentTypes returns the sorted list of types declared in the schema.
+func (s *TranslationSchema) DocumentTypes() []string {
+	types := []string{}
+	for documentType := range s.Types {
+		types = append(types, documentType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const studioSchemaExport = `[
+	{
+		"name": "test",
+		"type": "document",
+		"fields": [
+			{"name": "slug", "type": "slug"},
+			{"name": "title", "type": "string"},
+			{"name": "intro", "type": "string"},
+			{"name": "testArray", "type": "array", "of": [{"type": "string"}]},
+			{"name": "portableTest", "type": "array", "of": [{"type": "block"}]},
+			{"name": "sections", "type": "array", "of": [{"type": "hero"}]},
+			{"name": "seo", "type": "object", "fields": [
+				{"name": "metaTitle", "type": "string"},
+				{"name": "canonical", "type": "string", "translatable": false}
+			]},
+			{"name": "language", "type": "string", "readOnly": true, "hidden": true}
+		]
+	},
+	{
+		"name": "hero",
+		"type": "object",
+		"fields": [
+			{"name": "heading", "type": "string"},
+			{"name": "image", "type": "image"}
+		]
+	}
+]`
+
+func TestParseStudioSchema(t *testing.T) {
+	schema, err := ParseStudioSchema([]byte(studioSchemaExport))
+	if err != nil {
+		t.Fatalf("ParseStudioSchema() returned an error: %v", err)
+	}
+
+	want := []string{
+		"title",
+		"intro",
+		"testArray[*]",
+		`portableTest[_type=="block"].children[*].text`,
+		`sections[_type=="hero"].heading`,
+		"seo.metaTitle",
+	}
+	if got := schema.Types["test"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStudioSchema() = %v, want %v", got, want)
+	}
+	if _, ok := schema.Types["hero"]; ok {
+		t.Errorf("ParseStudioSchema() should only list document types")
+	}
+}
+
+func TestLoadTranslationSchemaYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	content := "test:\n  - title\n  - portableTest[*].children[*].text\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	schema, err := LoadTranslationSchema(path)
+	if err != nil {
+		t.Fatalf("LoadTranslationSchema() returned an error: %v", err)
+	}
+
+	elements := schema.InputElements("test")
+	if len(elements) != 2 || elements[1].Path != "portableTest[*].children[*].text" {
+		t.Errorf("InputElements() = %+v", elements)
+	}
+	if len(schema.InputElements("unknown")) != 0 {
+		t.Errorf("InputElements() of an unknown type should be empty")
+	}
+}
+
+func TestLoadTranslationSchemaInvalidSelector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yml")
+	if err := os.WriteFile(path, []byte("test:\n  - text[0.title\n"), 0644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	if _, err := LoadTranslationSchema(path); err == nil {
+		t.Errorf("LoadTranslationSchema() expected an error for an invalid selector")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultTargetConcurrency bounds the target languages translated at the same time.
+const DefaultTargetConcurrency = 4
+
+// Target is a language to translate a document into, and the slug of the translation.
+type Target struct {
+	Lang string
+	Slug string
+}
+
+// LanguageResult is the outcome of the translation into one target language.
+type LanguageResult struct {
+	Lang     string              `json:"lang"`
+	Slug     string              `json:"slug"`
+	ID       string              `json:"id,omitempty"`
+	Status   string              `json:"status"` // "translated" or "failed"
+	Error    string              `json:"error,omitempty"`
+	Skipped  []map[string]string `json:"skipped,omitempty"`
+	Document json.RawMessage     `json:"document,omitempty"` // Translated document, only for dry runs
+	Fields   []SanityField       `json:"fields,omitempty"`   // Translated fields, only for dry runs
+	err      error
+}
+
+// TargetList returns Targets, or the single ToLang/ToSlug target when Targets is empty.
+func (txx *SanityDocumentTranslator) TargetList() []Target {
+	if len(txx.Targets) > 0 {
+		return txx.Targets
+	}
+	return []Target{{Lang: txx.ToLang, Slug: txx.ToSlug}}
+}
+
+// TargetSlugs lists the slugs of the target documents.
+func (txx *SanityDocumentTranslator) TargetSlugs() string {
+	slugs := []string{}
+	for _, target := range txx.TargetList() {
+		slugs = append(slugs, target.Slug)
+	}
+	return strings.Join(slugs, ", ")
+}
+
+// ForTarget returns a copy of txx translating its collected fields into target.
+func (txx *SanityDocumentTranslator) ForTarget(target Target) *SanityDocumentTranslator {
+	translation := *txx
+	translation.ToLang = target.Lang
+	translation.ToSlug = target.Slug
+	translation.Targets = nil
+	translation.Fields = append([]SanityField{}, txx.Fields...)
+	translation.After = txx.Before
+	translation.target = ""
+	translation.records = nil
+	translation.copied = nil
+	return &translation
+}
+
+// TranslateTargets translates the fields collected in txx into every target
+// language, up to Concurrency at a time.
+func TranslateTargets(ctx context.Context, txx *SanityDocumentTranslator) ([]*SanityDocumentTranslator, []LanguageResult) {
+	targets := txx.TargetList()
+	translations := make([]*SanityDocumentTranslator, len(targets))
+	languages := make([]LanguageResult, len(targets))
+
+	concurrency := txx.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultTargetConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		translations[i] = txx.ForTarget(target)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			translation := translations[i]
+			language := LanguageResult{Lang: translation.ToLang, Slug: translation.ToSlug, Status: "translated"}
+			if err := translateTarget(ctx, translation); err != nil {
+				fmt.Fprintf(logOutput, "Failed translating to %s: %v\n", translation.ToLang, err)
+				language.Status = "failed"
+				language.Error = err.Error()
+				language.err = err
+			} else {
+				language.ID = gjson.Get(translation.After, "_id").String()
+				language.Skipped = SkippedFields(translation)
+				if txx.DryRun {
+					language.Document = json.RawMessage(translation.After)
+					language.Fields = translation.Fields
+				}
+			}
+			languages[i] = language
+		}(i)
+	}
+	wg.Wait()
+	return translations, languages
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSanityTranslateDocumentTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		check    func(t *testing.T, metadata gjson.Result)
+	}{
+		{
+			name:     "CreateMetadata",
+			metadata: `null`,
+			check: func(t *testing.T, metadata gjson.Result) {
+				keys := metadata.Get("create.translations.#._key").String()
+				if keys != `["en","it","fr","de"]` {
+					t.Errorf("metadata translations = %s, want en, it, fr and de", keys)
+				}
+			},
+		},
+		{
+			name:     "UpsertMetadata",
+			metadata: existingMetadata,
+			check: func(t *testing.T, metadata gjson.Result) {
+				patch := metadata.Get("patch")
+				if patch.Get("ifRevisionID").String() != "rev-2" {
+					t.Errorf("ifRevisionID = %q, want rev-2", patch.Get("ifRevisionID").String())
+				}
+				if patch.Get(`set.translations\[_key=="it"\]\.value._ref`).String() != "doc_it" {
+					t.Errorf("unexpected set: %s", patch.Get("set").Raw)
+				}
+				if patch.Get("insert.items.#._key").String() != `["fr","de"]` {
+					t.Errorf("unexpected insert: %s", patch.Get("insert").Raw)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceQueries := 0
+			fake := useFakeSanity(t, func(query string) string {
+				if strings.Contains(query, "translation.metadata") {
+					return tt.metadata
+				}
+				sourceQueries++
+				return `{"_id": "doc", "_type": "test", "slug": {"current": "/en/doc"}, "title": "Title"}`
+			})
+			w := serveTranslateDocument(t, `{"FromLang": "en", "FromSlug": "/en/doc", "InputElements": ["title"], "Concurrency": 2,
+				"Targets": [{"Lang": "it", "Slug": "/it/doc"}, {"Lang": "fr", "Slug": "/fr/doc"}, {"Lang": "de", "Slug": "/de/doc"}]}`)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %v, got %v: %s", tt.name, http.StatusOK, w.Code, w.Body.String())
+			}
+			if sourceQueries != 1 {
+				t.Errorf("%s: fetched the source %d times, want 1", tt.name, sourceQueries)
+			}
+			if len(fake.mutations) != 1 {
+				t.Fatalf("%s: sent %d mutate requests, want 1", tt.name, len(fake.mutations))
+			}
+
+			mutations := gjson.Get(fake.mutations[0], "mutations").Array()
+			if len(mutations) != 4 {
+				t.Fatalf("%s: transaction has %d mutations, want 3 documents and the metadata", tt.name, len(mutations))
+			}
+			for i, lang := range []string{"it", "fr", "de"} {
+				document := mutations[i].Get("createOrReplace")
+				if document.Get("_id").String() != "doc_"+lang || document.Get("slug.current").String() != "/"+lang+"/doc" ||
+					document.Get("language").String() != lang || document.Get("title").String() != "IT: Title" {
+					t.Errorf("%s: unexpected %s document: %s", tt.name, lang, document.Raw)
+				}
+			}
+			tt.check(t, mutations[3])
+
+			languages := gjson.Get(w.Body.String(), "languages").Array()
+			if len(languages) != 3 {
+				t.Fatalf("%s: languages = %s, want 3 entries", tt.name, gjson.Get(w.Body.String(), "languages").Raw)
+			}
+			for _, language := range languages {
+				if language.Get("status").String() != "translated" || language.Get("id").String() != "doc_"+language.Get("lang").String() {
+					t.Errorf("%s: unexpected language result %s", tt.name, language.Raw)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDocumentRequestTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		targets    []Target
+		glossaryID string
+		wantErr    bool
+	}{
+		{name: "NoTargets"},
+		{name: "ValidTargets", targets: []Target{{Lang: "it", Slug: "/it/doc"}, {Lang: "fr", Slug: "/fr/doc"}}},
+		{name: "MissingSlug", targets: []Target{{Lang: "it"}}, wantErr: true},
+		{name: "DuplicateLanguage", targets: []Target{{Lang: "it", Slug: "/it/a"}, {Lang: "it", Slug: "/it/b"}}, wantErr: true},
+		{name: "GlossaryForOneTarget", targets: []Target{{Lang: "it", Slug: "/it/doc"}}, glossaryID: "g-1"},
+		{name: "GlossaryForSeveralTargets", targets: []Target{{Lang: "it", Slug: "/it/doc"}, {Lang: "fr", Slug: "/fr/doc"}}, glossaryID: "g-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDocumentRequest(&SanityDocumentTranslator{Targets: tt.targets, GlossaryID: tt.glossaryID})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s: ValidateDocumentRequest() = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
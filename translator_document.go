@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tidwall/gjson"
@@ -14,102 +15,286 @@ import (
 )
 
 // SanityTranslateDocument handles the main logic for translating Sanity documents.
+// With Async set the translation is queued as a job and its id returned right away.
 func SanityTranslateDocument(c *gin.Context) {
 
 	var txx SanityDocumentTranslator
 
-	err := errors.New("")
-
 	// Create a Translator object adding all the info from the request
-	if err = c.BindJSON(&txx); err != nil {
+	if err := c.BindJSON(&txx); err != nil {
 		c.String(http.StatusBadRequest, "Failed binding event to JSON")
-		fmt.Println("Failed binding event to JSON")
+		fmt.Fprintln(logOutput, "Failed binding event to JSON")
 		return
 	}
 
-	fmt.Printf("Translating from: %s\n", txx.FromSlug)
+	if err := ValidateDocumentRequest(&txx); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		fmt.Fprintln(logOutput, err)
+		return
+	}
 
-	// Create a SanityDocument object adding all the info from Sanity API
-	query := fmt.Sprintf(`*[slug.current == '%s'][0]`, txx.FromSlug)
-	txx.Before, err = RunQuery(query)
-	if err != nil || txx.Before == "" {
-		c.String(http.StatusBadRequest, "Error extracting original_doc from Sanity")
-		fmt.Println("Error extracting original_doc from Sanity")
+	if txx.Async {
+		EnqueueJob(c, "Document translation "+txx.FromSlug+" to "+txx.TargetSlugs(), func(ctx context.Context, job *Job) (interface{}, error) {
+			txx.hooks = job.TranslationHooks()
+			return TranslateDocument(ctx, &txx)
+		})
 		return
 	}
-	result := gjson.Get(txx.Before, "result").Raw
-	txx.Id = gjson.Get(result, "_id").String()
-	txx.Before = result
-	txx.After = result
 
-	// Update current response with new info necessary to Sanity
-	err = EvolveSanityResponse(&txx)
+	result, err := TranslateDocument(c.Request.Context(), &txx)
 	if err != nil {
-		c.String(http.StatusBadRequest, "Failed evolving Sanity response")
-		fmt.Println("Failed evolving Sanity response")
+		RespondDocumentError(c, result, err)
 		return
 	}
 
-	// Execute all the translations required
-	m := map[string]interface{}{}
-	err = json.Unmarshal([]byte(txx.Before), &m)
-	err = ExecuteTranslation(&txx, m, "")
-	if err != nil {
-		c.String(http.StatusBadRequest, "Failed executing translations")
-		fmt.Println("Failed executing translations")
+	if txx.DryRun {
+		response := gin.H{
+			"status":    "success",
+			"message":   "Dry run completed, nothing was written",
+			"document":  result.Document,
+			"fields":    result.Fields,
+			"skipped":   result.Skipped,
+			"languages": result.Languages,
+		}
+		if result.Validation != nil {
+			response["validation"] = result.Validation
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
-	for _, element := range txx.Fields {
-		txx.After, _ = sjson.Set(
-			txx.After,
-			element.Path,
-			element.TranslatedContent,
-		)
-	}
-
-	// Push document to Sanity
-	newDocumentMutation := fmt.Sprintf(`
-		{
-			"mutations": [
-				{
-					"createOrReplace": %s
-				}
-			]
-		}`,
-		txx.After,
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":        "success",
+			"message":       "Document translation completed",
+			"transactionId": result.TransactionID,
+			"skipped":       result.Skipped,
+			"languages":     result.Languages,
+		},
 	)
-	err = RunMutation(newDocumentMutation)
-	if err != nil {
-		c.String(http.StatusBadRequest, "Pushing new document to Sanity")
-		fmt.Println("Pushing new document to Sanity")
-		return
+}
+
+// DocumentResult is the outcome of TranslateDocument.
+type DocumentResult struct {
+	TransactionID string              `json:"transactionId,omitempty"`
+	Skipped       []map[string]string `json:"skipped"`
+	Document      json.RawMessage     `json:"document,omitempty"`   // Translated document, only for dry runs
+	Fields        []SanityField       `json:"fields,omitempty"`     // Translated fields, only for dry runs
+	Validation    *MutationResult     `json:"validation,omitempty"` // Sanity dry run result, only with ValidateWrite
+	Languages     []LanguageResult    `json:"languages"`            // Outcome of each target language
+}
+
+// StepError tells which step of TranslateDocument failed.
+type StepError struct {
+	Message string
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	if e.Err == nil {
+		return e.Message
 	}
+	return e.Message + ": " + e.Err.Error()
+}
 
-	// Update translation metadata
-	err = ManageTranslationMetadata(&txx)
-	if err != nil {
-		c.String(http.StatusBadRequest, "Failed managing translation metadata")
-		fmt.Println("Failed managing translation metadata")
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// stepFailed prints and returns the failure of a TranslateDocument step
+func stepFailed(message string, err error) error {
+	fmt.Fprintln(logOutput, message)
+	return &StepError{Message: message, Err: err}
+}
+
+// RespondStepError writes the response of a failed step: translation provider
+// errors get their own status, other failures a 400 with the step message
+func RespondStepError(c *gin.Context, err error) {
+	message := err.Error()
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		message = stepErr.Message
+	}
+
+	var translationErr *TranslationError
+	if errors.As(err, &translationErr) {
+		AbortWithTranslationError(c, message, err)
 		return
 	}
+	c.String(http.StatusBadRequest, message)
+}
 
-	fmt.Printf("Translating to: %s\n\n", txx.ToSlug)
+// RespondDocumentError writes the response of a failed TranslateDocument
+func RespondDocumentError(c *gin.Context, result DocumentResult, err error) {
+	var translationErr *TranslationError
+	if result.Document == nil || errors.As(err, &translationErr) {
+		RespondStepError(c, err)
+		return
+	}
 
+	message := err.Error()
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		message = stepErr.Message
+	}
 	c.JSON(
-		http.StatusOK,
+		http.StatusBadRequest,
 		gin.H{
-			"status":  "success",
-			"message": "Document translation completed",
+			"status":   "error",
+			"message":  message,
+			"error":    err.Error(),
+			"document": result.Document,
+			"fields":   result.Fields,
 		},
 	)
 }
 
+// TranslateDocument fetches the source document once, translates it into every target
+// language and writes the translations with a single translation.metadata update, or only
+// computes them when DryRun is set. The request must have been checked with ValidateDocumentRequest.
+func TranslateDocument(ctx context.Context, txx *SanityDocumentTranslator) (DocumentResult, error) {
+	var result DocumentResult
+
+	fmt.Fprintf(logOutput, "Translating from: %s\n", txx.FromSlug)
+
+	// Create a SanityDocument object adding all the info from Sanity API
+	response, err := sanityFor(txx.Dataset).Query(SourceDocumentQuery(txx.FromDraft), map[string]interface{}{"slug": txx.FromSlug})
+	if err != nil || !gjson.Get(response, "result").IsObject() {
+		return result, stepFailed("Error extracting original_doc from Sanity", err)
+	}
+	source := gjson.Get(response, "result").Raw
+	txx.Id = publishedID(gjson.Get(source, "_id").String())
+	txx.Before = source
+	txx.After = source
+
+	// Derive the selectors from the translation schema when none are given
+	if len(txx.InputElements) == 0 {
+		documentType := gjson.Get(source, "_type").String()
+		txx.InputElements = ActiveSchema.InputElements(documentType)
+		if len(txx.InputElements) == 0 {
+			return result, stepFailed("No InputElements given and no translation schema for type "+documentType, nil)
+		}
+	}
+
+	// Collect the fields to translate, shared by every target language
+	m := map[string]interface{}{}
+	err = json.Unmarshal([]byte(txx.Before), &m)
+	if err == nil {
+		err = ExecuteTranslation(txx, m, "")
+	}
+	if err != nil {
+		return result, stepFailed("Failed executing translations", err)
+	}
+
+	translations, languages := TranslateTargets(ctx, txx)
+	result.Languages = languages
+	succeeded := []*SanityDocumentTranslator{}
+	var firstErr error
+	for i, translation := range translations {
+		if languages[i].err != nil {
+			if firstErr == nil {
+				firstErr = languages[i].err
+			}
+			continue
+		}
+		succeeded = append(succeeded, translation)
+	}
+	if len(succeeded) == 0 {
+		return result, firstErr
+	}
+	if len(translations) == 1 {
+		result.Skipped = languages[0].Skipped
+		result.Document = languages[0].Document
+		result.Fields = languages[0].Fields
+	}
+
+	if txx.DryRun {
+		if txx.ValidateWrite {
+			validation, err := CommitTranslations(succeeded, MutationOptions{DryRun: true})
+			if err != nil {
+				fmt.Fprintln(logOutput, "Sanity rejected the translated document:", err)
+				return result, &StepError{Message: "Sanity rejected the translated document", Err: err}
+			}
+			result.Validation = &validation
+		}
+		fmt.Fprintf(logOutput, "Dry run to: %s\n\n", txx.TargetSlugs())
+		return result, nil
+	}
+
+	if err = ctx.Err(); err != nil {
+		return result, stepFailed("Translation cancelled before committing", err)
+	}
+
+	// Push the documents and translation metadata to Sanity in a single transaction
+	transaction, err := CommitTranslations(succeeded, MutationOptions{})
+	if err != nil {
+		return result, stepFailed("Failed committing translation to Sanity", err)
+	}
+	result.TransactionID = transaction.TransactionID
+
+	fmt.Fprintf(logOutput, "Translating to: %s\n\n", txx.TargetSlugs())
+	return result, nil
+}
+
+// translateTarget translates the collected fields of txx into its target language
+// and builds the target document
+func translateTarget(ctx context.Context, txx *SanityDocumentTranslator) error {
+	// Update current response with new info necessary to Sanity
+	if err := EvolveSanityResponse(txx); err != nil {
+		return stepFailed("Failed evolving Sanity response", err)
+	}
+
+	if txx.Merge || txx.Incremental {
+		if err := LoadTargetDocument(txx); err != nil {
+			return stepFailed("Failed extracting the target document", err)
+		}
+	}
+	SkipUnchangedFields(txx)
+	if txx.Merge {
+		if err := MergeWithTarget(txx); err != nil {
+			return stepFailed("Failed merging with the target document", err)
+		}
+	}
+	if err := TranslateFields(ctx, txx); err != nil {
+		return stepFailed("Failed executing translations", err)
+	}
+	err := ApplyFields(txx)
+	if err == nil {
+		err = RecordTranslationMeta(txx)
+	}
+	if err != nil {
+		return stepFailed("Failed applying translations", err)
+	}
+	return nil
+}
+
+// draftsPrefix is the id prefix of unpublished Sanity documents
+const draftsPrefix = "drafts."
+
+// publishedID strips the drafts. prefix from a document id
+func publishedID(id string) string {
+	return strings.TrimPrefix(id, draftsPrefix)
+}
+
+// SourceDocumentQuery returns the query fetching the document to translate by $slug:
+// the published document, or its draft first when fromDraft is set
+func SourceDocumentQuery(fromDraft bool) string {
+	if fromDraft {
+		return `*[slug.current == $slug] | order(_id in path("drafts.**") desc)[0]`
+	}
+	return `*[slug.current == $slug && !(_id in path("drafts.**"))][0]`
+}
+
 // EvolveSanityResponse updates the response with new info necessary to Sanity
 func EvolveSanityResponse(txx *SanityDocumentTranslator) (err error) {
 
 	// Set id
-	old_id := gjson.Get(txx.Before, "_id").Str
+	old_id := publishedID(gjson.Get(txx.Before, "_id").Str)
 	new_id := old_id + fmt.Sprintf(`_%s`, txx.ToLang)
+	if txx.Draft != "" {
+		new_id = draftsPrefix + new_id
+	}
 	txx.After, err = sjson.Set(
 		txx.After,
 		"_id",
@@ -133,10 +318,25 @@ func EvolveSanityResponse(txx *SanityDocumentTranslator) (err error) {
 	return err
 }
 
-// ExecuteTranslation executes the translation for the given path using Deepl API
+// ExecuteTranslation walks the document and collects every field matching InputElements
 func ExecuteTranslation(txx *SanityDocumentTranslator, val interface{}, path string) error {
 	switch v := val.(type) {
 	case map[string]interface{}:
+		if txx.PortableText && isPortableTextBlock(v) {
+			children := v["children"].([]interface{})
+			element, ok := matchBlock(txx, path, children)
+			if ok {
+				opts := txx.Options.Merge(element.Options)
+				opts.TagHandling = "xml"
+				txx.Fields = append(txx.Fields, SanityField{
+					Path:            path,
+					Kind:            FieldPortableBlock,
+					OriginalContent: SerializeBlock(children),
+					Options:         opts,
+				})
+				return nil
+			}
+		}
 		for key, subVal := range v {
 			subPath := ""
 			if path == "" {
@@ -146,7 +346,7 @@ func ExecuteTranslation(txx *SanityDocumentTranslator, val interface{}, path str
 			}
 			err := ExecuteTranslation(txx, subVal, subPath)
 			if err != nil {
-				fmt.Println("Error while parsing fields")
+				fmt.Fprintln(logOutput, "Error while parsing fields")
 				return err
 			}
 		}
@@ -155,133 +355,486 @@ func ExecuteTranslation(txx *SanityDocumentTranslator, val interface{}, path str
 			subPath := path + "." + strconv.Itoa(i)
 			err := ExecuteTranslation(txx, subVal, subPath)
 			if err != nil {
-				fmt.Println("Error while parsing fields")
+				fmt.Fprintln(logOutput, "Error while parsing fields")
 				return err
 			}
 		}
+	case string:
+		element, ok := matchInputElement(txx, path)
+		if !ok || v == "" {
+			return nil
+		}
+		if reason := txx.LeafRules.SkipReason(path, v); reason != "" {
+			fmt.Fprintf(logOutput, "\tSkipping %s: %s\n", path, reason)
+			return nil
+		}
+		txx.Fields = append(txx.Fields, SanityField{
+			Path:            path,
+			OriginalContent: v,
+			Options:         txx.Options.Merge(element.Options),
+		})
 	default:
-		for _, translation := range txx.InputElements {
-			if cleanString(translation) == cleanString(path) {
-				if fmt.Sprintf("%v", v) == "" {
-					continue
-				}
-				time.Sleep(1 * time.Second) // Deepl API rate limit
-				trax, err := RunDeepl(
-					fmt.Sprintf("%v", v),
-					txx.FromLang,
-					txx.ToLang,
-				)
-				if err != nil {
-					fmt.Println("Error while translating path")
-					return err
-				}
-				txx.Fields = append(
-					txx.Fields,
-					SanityField{
-						Path:              path,
-						OriginalContent:   fmt.Sprintf("%v", v),
-						TranslatedContent: trax,
-					},
-				)
+		// Numbers, booleans and nulls are never translated
+	}
+	return nil
+}
+
+// SkipUnchangedFields marks skipped the fields whose source did not change
+// since the last translation into txx.ToLang
+func SkipUnchangedFields(txx *SanityDocumentTranslator) {
+	for i := range txx.Fields {
+		field := &txx.Fields[i]
+		if unchangedSource(txx, *field) {
+			fmt.Fprintf(logOutput, "\tSkipping %s (%s): source unchanged\n", field.Path, txx.ToLang)
+			field.SkipReason = "source unchanged since the last translation"
+		}
+		txx.hooks.fieldFound(txx.ToLang, *field)
+	}
+}
+
+// matchBlock returns the InputElement selecting the Portable Text block at path,
+// either through the block path itself or through the text of any of its children
+func matchBlock(txx *SanityDocumentTranslator, path string, children []interface{}) (InputElement, bool) {
+	if element, ok := matchInputElement(txx, path); ok {
+		return element, true
+	}
+	for i, child := range children {
+		if span, _ := child.(map[string]interface{}); span == nil || span["text"] == nil {
+			continue
+		}
+		if element, ok := matchInputElement(txx, path+".children."+strconv.Itoa(i)+".text"); ok {
+			return element, true
+		}
+	}
+	return InputElement{}, false
+}
+
+// matchInputElement returns the first InputElement selecting path,
+// unless an exclusion (!selector) matches it
+func matchInputElement(txx *SanityDocumentTranslator, path string) (InputElement, bool) {
+	var found *InputElement
+	for i := range txx.InputElements {
+		element := &txx.InputElements[i]
+		if element.selector == nil {
+			selector, err := ParseSelector(element.Path)
+			if err != nil {
+				continue
+			}
+			element.selector = &selector
+		}
+		if !element.selector.Match(path, txx.Before) {
+			continue
+		}
+		if element.selector.Exclude {
+			return InputElement{}, false
+		}
+		if found == nil {
+			found = element
+		}
+	}
+	if found == nil {
+		return InputElement{}, false
+	}
+	return *found, true
+}
+
+// ApplyFields writes the translated fields into the After document, except the skipped ones
+func ApplyFields(txx *SanityDocumentTranslator) (err error) {
+	for _, element := range txx.Fields {
+		if element.SkipReason != "" {
+			txx.After, err = keepTargetValue(txx, element)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		switch element.Kind {
+		case FieldPortableBlock:
+			childrenPath := element.Path + ".children"
+			children, _ := gjson.Get(txx.Before, childrenPath).Value().([]interface{})
+			translated, err := ParseBlock(element.TranslatedContent, children)
+			if err != nil {
+				fmt.Fprintln(logOutput, "Error while parsing translated block:", element.Path)
+				return err
+			}
+			txx.After, err = sjson.Set(txx.After, childrenPath, translated)
+			if err != nil {
+				return err
+			}
+		default:
+			txx.After, err = sjson.Set(
+				txx.After,
+				element.Path,
+				element.TranslatedContent,
+			)
+			if err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// TranslateFields translates all the collected fields in batches and maps the results back by position.
+// Fields sharing the same options are sent together.
+func TranslateFields(ctx context.Context, txx *SanityDocumentTranslator) error {
+	groups := map[TranslationOptions][]int{}
+	order := []TranslationOptions{}
+	pending := 0
+	for i, field := range txx.Fields {
+		if field.SkipReason != "" {
+			continue
+		}
+		pending++
+		opts := field.Options
+		if opts.GlossaryID == "" {
+			opts.GlossaryID = ResolveGlossaryID(txx.GlossaryID, txx.Glossaries, txx.FromLang, txx.ToLang)
+		}
+		if _, ok := groups[opts]; !ok {
+			order = append(order, opts)
+		}
+		groups[opts] = append(groups[opts], i)
+	}
+
+	txx.hooks.fieldsPending(pending)
+
+	for _, opts := range order {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		indexes := groups[opts]
+		texts := make([]string, len(indexes))
+		for i, index := range indexes {
+			texts[i] = txx.Fields[index].OriginalContent
+		}
+
+		translations, err := ActiveTranslator.TranslateBatch(ctx, texts, txx.FromLang, txx.ToLang, opts)
+		if err != nil {
+			fmt.Fprintln(logOutput, "Error while translating fields")
+			return err
+		}
+
+		for i, index := range indexes {
+			txx.Fields[index].TranslatedContent = translations[i]
+			txx.hooks.fieldTranslated(txx.ToLang, txx.Fields[index])
+		}
+	}
+	return nil
+}
+
+// ValidateDocumentRequest checks the request options and parses every InputElements selector
+func ValidateDocumentRequest(txx *SanityDocumentTranslator) error {
+	if err := txx.Options.Validate(); err != nil {
+		return fmt.Errorf("invalid Options: %w", err)
+	}
+	if txx.Draft != "" && txx.Draft != "replace" && txx.Draft != "patch" {
+		return fmt.Errorf("invalid Draft: %s", txx.Draft)
+	}
+	languages := map[string]bool{}
+	for _, target := range txx.Targets {
+		if target.Lang == "" || target.Slug == "" {
+			return fmt.Errorf("invalid Targets: every target needs a Lang and a Slug")
+		}
+		if languages[target.Lang] {
+			return fmt.Errorf("invalid Targets: %s is listed twice", target.Lang)
+		}
+		languages[target.Lang] = true
+	}
+	if txx.Concurrency < 0 {
+		return fmt.Errorf("invalid Concurrency: %d", txx.Concurrency)
+	}
+	if err := validateGlossaryID(txx.GlossaryID, len(txx.TargetList())); err != nil {
+		return err
+	}
+	for i, element := range txx.InputElements {
+		if err := element.Options.Validate(); err != nil {
+			return fmt.Errorf("invalid Options for %s: %w", element.Path, err)
+		}
+		selector, err := ParseSelector(element.Path)
+		if err != nil {
+			return fmt.Errorf("invalid InputElements: %w", err)
+		}
+		txx.InputElements[i].selector = &selector
+	}
+	return nil
+}
+
+// metadataMaxAttempts bounds the retries when translation.metadata is modified concurrently
+const metadataMaxAttempts = 3
+
 // ManageTranslationMetadata updates the translation metadata document to keep reference in sync
 func ManageTranslationMetadata(txx *SanityDocumentTranslator) error {
-    fmt.Println("\n=== Managing Translation Metadata ===")
-    fmt.Printf("Looking for document with slug: %s\n", txx.FromSlug)
-    
-    query := fmt.Sprintf(`*[slug.current == '%s']{
-        "translation": *[
-            _type == "translation.metadata" &&
-            references(^._id)
-        ]
-    }`, txx.FromSlug)
-
-    document, err := RunQuery(query)
-    if err != nil {
-        fmt.Printf("❌ Error extracting translation.metadata from Sanity: %v\n", err)
-        return err
-    }
-
-    result := gjson.Get(document, "result")
-    if !result.Exists() || len(result.Array()) == 0 {
-        fmt.Println("ℹ️ No document found - Creating new translation metadata")
-        // ...existing code...
-        fmt.Printf("✅ Created new translation metadata with ID: %s\n", txx.Id+"_base")
-        return nil
-    }
-
-    translations := gjson.Get(document, "result.#.translation")
-    if !translations.Exists() || len(translations.Array()) == 0 {
-        fmt.Println("ℹ️ No existing translations found - Creating new translation metadata")
-        // ...existing code...
-        fmt.Printf("✅ Created new translation metadata with ID: %s\n", txx.Id+"_base")
-        return nil
-    }
-
-    ids := gjson.Get(document, "result.#.translation.#._id").Array()
-    if len(ids) == 0 || len(ids[0].Array()) == 0 {
-        fmt.Println("❌ No translation metadata ID found")
-        return fmt.Errorf("no translation metadata ID found")
-    }
-
-    id := ids[0].Array()[0].String()
-    fmt.Printf("📝 Found existing translation metadata with ID: %s\n", id)
-    
-    languages := gjson.Get(document, "result.#.translation.#.translations.#._key")
-
-    isEmpty := true
-    for _, innerSlice := range languages.Array() {
-        if len(innerSlice.Array()) != 0 {
-            isEmpty = false
-            break
-        }
-    }
-
-    if isEmpty {
-        fmt.Println("ℹ️ Translations array is empty - Creating new translation metadata")
-        // ...existing code...
-        fmt.Printf("✅ Created new translation metadata with ID: %s\n", txx.Id+"_base")
-        return nil
-    }
-
-    fmt.Printf("📝 Adding translation for language: %s\n", txx.ToLang)
-    rawPatch := fmt.Sprintf(`
-    {
-        "mutations": [
-            {
-                "patch": {
-                    "id": "%s",
-                    "insert": {
-                        "after": "translations[-1]",
-                        "items": [
-                            {
-                                "_key": "%s",
-                                "value": {
-                                    "_ref": "%s",
-                                    "_type": "reference"
-                                }
-                            }
-                        ]
-                    }
-                }
-            }
-        ]
-    }`,
-        id,
-        txx.ToLang,
-        gjson.Get(txx.After, "_id").String(),
-    )
-    err = RunMutation(rawPatch)
-    if err != nil {
-        fmt.Printf("❌ Error running mutation: %v\n", err)
-        return err
-    }
-    fmt.Printf("✅ Successfully added translation for language: %s\n", txx.ToLang)
-    fmt.Println("=== Translation Metadata Management Complete ===\n")
-    return nil
+	_, err := commitWithMetadata([]*SanityDocumentTranslator{txx}, MutationOptions{})
+	return err
+}
+
+// CommitTranslation writes the translated document and links it in translation.metadata
+// within one transaction, so a failure never leaves an orphan translation behind
+func CommitTranslation(txx *SanityDocumentTranslator, opts MutationOptions) (MutationResult, error) {
+	return CommitTranslations([]*SanityDocumentTranslator{txx}, opts)
+}
+
+// CommitTranslations writes the translations of one source document into several
+// languages, and links all of them in translation.metadata, within one transaction
+func CommitTranslations(targets []*SanityDocumentTranslator, opts MutationOptions) (MutationResult, error) {
+	mutations := make([]Mutation, len(targets))
+	for i, txx := range targets {
+		documentMutation, err := PlanDocumentMutation(txx)
+		if err != nil {
+			return MutationResult{}, err
+		}
+		mutations[i] = documentMutation
+	}
+	return commitWithMetadata(targets, opts, mutations...)
+}
+
+// PlanDocumentMutation returns the mutation writing the translated document. In "patch"
+// draft mode an existing draft only gets the translated paths, other fields are kept.
+func PlanDocumentMutation(txx *SanityDocumentTranslator) (Mutation, error) {
+	if txx.Draft != "patch" {
+		return Mutation{CreateOrReplace: json.RawMessage(txx.After)}, nil
+	}
+
+	draftID := gjson.Get(txx.After, "_id").String()
+	existing, err := sanityFor(txx.Dataset).WithoutCDN().Query(
+		`*[_id == $id][0]{_id}`,
+		map[string]interface{}{"id": draftID},
+	)
+	if err != nil {
+		fmt.Fprintln(logOutput, "Error looking for existing draft:", draftID)
+		return Mutation{}, err
+	}
+	if !gjson.Get(existing, "result._id").Exists() {
+		fmt.Fprintf(logOutput, "No draft %s found, creating it\n", draftID)
+		return Mutation{CreateOrReplace: json.RawMessage(txx.After)}, nil
+	}
+
+	set := map[string]interface{}{
+		translationMetaField: gjson.Get(txx.After, translationMetaField).Value(),
+	}
+	for _, field := range txx.Fields {
+		if field.SkipReason != "" {
+			continue
+		}
+		path := field.Path
+		if field.Kind == FieldPortableBlock {
+			path += ".children"
+		}
+		set[toSanityPath(path)] = gjson.Get(txx.After, path).Value()
+	}
+	fmt.Fprintf(logOutput, "Patching %d fields of draft %s\n", len(set), draftID)
+	return Mutation{Patch: &Patch{ID: draftID, Set: set}}, nil
+}
+
+// toSanityPath converts a dotted path (text.0.title) into a Sanity patch path (text[0].title)
+func toSanityPath(path string) string {
+	var sb strings.Builder
+	for i, segment := range strings.Split(path, ".") {
+		if isDigits(segment) {
+			sb.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(segment)
+	}
+	return sb.String()
+}
+
+// commitWithMetadata submits mutations together with the translation.metadata update
+// linking targets, all translations of the same source document, planning the update
+// again when the metadata changed concurrently
+func commitWithMetadata(targets []*SanityDocumentTranslator, opts MutationOptions, mutations ...Mutation) (MutationResult, error) {
+	txx := targets[0]
+	fmt.Fprintln(logOutput, "\n=== Managing Translation Metadata ===")
+	fmt.Fprintf(logOutput, "Looking for translation metadata of: %s\n", txx.Id)
+
+	for attempt := 1; attempt <= metadataMaxAttempts; attempt++ {
+		metadataMutation, err := PlanTranslationMetadata(targets...)
+		if err != nil {
+			return MutationResult{}, err
+		}
+		txx.hooks.metadataPlanned(metadataMutation)
+
+		transaction := append(append([]Mutation{}, mutations...), metadataMutation)
+		result, err := sanityFor(txx.Dataset).MutateWithOptions(opts, transaction...)
+		txx.hooks.mutationSent(attempt, result, err)
+		if err == nil {
+			for _, target := range targets {
+				fmt.Fprintf(logOutput, "✅ Successfully linked translation for language: %s\n", target.ToLang)
+			}
+			fmt.Fprintf(logOutput, "Transaction: %s\n", result.TransactionID)
+			fmt.Fprintln(logOutput, "=== Translation Metadata Management Complete ===")
+			fmt.Fprintln(logOutput, "")
+			return result, nil
+		}
+		if !isRevisionConflict(err) {
+			fmt.Fprintf(logOutput, "❌ Error running mutation: %v\n", err)
+			return MutationResult{}, err
+		}
+		fmt.Fprintf(logOutput, "⚠️ Translation metadata changed concurrently, retrying (%d/%d)\n", attempt, metadataMaxAttempts)
+	}
+	return MutationResult{}, fmt.Errorf("translation metadata kept changing after %d attempts", metadataMaxAttempts)
+}
+
+// translationMetadataQuery fetches the translation.metadata referencing $id or, when
+// there is none, the document with the id new metadata would be created with ($baseId)
+const translationMetadataQuery = `coalesce(
+	*[_type == "translation.metadata" && references($id)][0],
+	*[_id == $baseId][0]
+)`
+
+// PlanTranslationMetadata fetches the translation.metadata referencing the source document
+// and returns the mutation linking the translations in targets to it
+func PlanTranslationMetadata(targets ...*SanityDocumentTranslator) (Mutation, error) {
+	txx := targets[0]
+
+	document, err := sanityFor(txx.Dataset).WithoutCDN().Query(
+		translationMetadataQuery,
+		map[string]interface{}{"id": txx.Id, "baseId": translationMetadataID(txx.Id)},
+	)
+	if err != nil {
+		fmt.Fprintf(logOutput, "❌ Error extracting translation.metadata from Sanity: %v\n", err)
+		return Mutation{}, err
+	}
+
+	metadata := gjson.Get(document, "result")
+	if !metadata.IsObject() {
+		fmt.Fprintln(logOutput, "ℹ️ No existing translations found - Creating new translation metadata")
+		// Create fails if another request created it meanwhile, and the caller retries
+		return Mutation{Create: buildTranslationMetadata(targets)}, nil
+	}
+	if err := checkBaseMetadata(metadata, txx.Id); err != nil {
+		fmt.Fprintf(logOutput, "❌ %v\n", err)
+		return Mutation{}, err
+	}
+
+	fmt.Fprintf(logOutput, "📝 Found existing translation metadata with ID: %s\n", metadata.Get("_id").String())
+	if !referencesDocument(metadata, txx.Id) && len(metadata.Get("translations").Array()) > 0 {
+		// Metadata found by id only: link the source document too
+		source := *txx
+		source.ToLang = txx.FromLang
+		source.After = fmt.Sprintf(`{"_id": %q}`, txx.Id)
+		targets = append([]*SanityDocumentTranslator{&source}, targets...)
+	}
+	return upsertTranslationMutation(targets, metadata), nil
+}
+
+// translationMetadataID returns the id of the translation.metadata created for the source document id
+func translationMetadataID(id string) string {
+	return id + "_base"
+}
+
+// referencesDocument reports whether the translation.metadata references the document id
+func referencesDocument(metadata gjson.Result, id string) bool {
+	for _, ref := range metadata.Get("translations.#.value._ref").Array() {
+		if ref.String() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBaseMetadata fails when metadata, found by the id of new translation.metadata
+// rather than by its reference to the document id, is another kind of document
+func checkBaseMetadata(metadata gjson.Result, id string) error {
+	if referencesDocument(metadata, id) || metadata.Get("_type").String() == "translation.metadata" {
+		return nil
+	}
+	return fmt.Errorf("document %s already exists and is not a translation.metadata", metadata.Get("_id").String())
+}
+
+// upsertTranslationMutation returns the patch replacing the reference of each target
+// language in metadata, or inserting it when missing. The patch only applies to the revision read.
+func upsertTranslationMutation(targets []*SanityDocumentTranslator, metadata gjson.Result) Mutation {
+	patch := &Patch{
+		ID:           metadata.Get("_id").String(),
+		IfRevisionID: metadata.Get("_rev").String(),
+	}
+
+	translations := metadata.Get("translations").Array()
+	if len(translations) == 0 {
+		fmt.Fprintln(logOutput, "ℹ️ Translations array is empty - Filling existing translation metadata")
+		fresh := buildTranslationMetadata(targets)
+		patch.Set = map[string]interface{}{"translations": fresh["translations"]}
+		patch.SetIfMissing = map[string]interface{}{"schemaTypes": fresh["schemaTypes"]}
+		return Mutation{Patch: patch}
+	}
+
+	existing := map[string]bool{}
+	for _, translation := range translations {
+		existing[translation.Get("_key").String()] = true
+	}
+
+	inserted := []interface{}{}
+	for _, txx := range targets {
+		targetReference := targetTranslationReference(txx)
+		if !existing[txx.ToLang] {
+			fmt.Fprintf(logOutput, "📝 Adding translation for language: %s\n", txx.ToLang)
+			inserted = append(inserted, targetReference)
+			continue
+		}
+		fmt.Fprintf(logOutput, "📝 Replacing translation for language: %s\n", txx.ToLang)
+		if patch.Set == nil {
+			patch.Set = map[string]interface{}{}
+		}
+		patch.Set[fmt.Sprintf(`translations[_key==%q].value`, txx.ToLang)] = targetReference["value"]
+	}
+	if len(inserted) > 0 {
+		patch.Insert = &Insert{
+			After: "translations[-1]",
+			Items: inserted,
+		}
+	}
+	return Mutation{Patch: patch}
+}
+
+// buildTranslationMetadata returns a translation.metadata document, as expected by
+// @sanity/document-internationalization, referencing the source and target languages
+func buildTranslationMetadata(targets []*SanityDocumentTranslator) map[string]interface{} {
+	txx := targets[0]
+	schemaTypes := []string{}
+	if documentType := gjson.Get(txx.Before, "_type").String(); documentType != "" {
+		schemaTypes = append(schemaTypes, documentType)
+	}
+
+	translations := []interface{}{translationReference(txx.FromLang, txx.Id)}
+	for _, target := range targets {
+		translations = append(translations, targetTranslationReference(target))
+	}
+	return map[string]interface{}{
+		"_id":          translationMetadataID(txx.Id),
+		"_type":        "translation.metadata",
+		"translations": translations,
+		"schemaTypes":  schemaTypes,
+	}
+}
+
+// targetTranslationReference builds the translations[] item of the translated document.
+// Drafts are referenced weakly by their published id, as the plugin does, and the
+// reference is strengthened when the translator publishes them.
+func targetTranslationReference(txx *SanityDocumentTranslator) map[string]interface{} {
+	targetID := gjson.Get(txx.After, "_id").String()
+	reference := translationReference(txx.ToLang, publishedID(targetID))
+	if strings.HasPrefix(targetID, draftsPrefix) {
+		value := reference["value"].(map[string]interface{})
+		value["_weak"] = true
+		value["_strengthenOnPublish"] = map[string]interface{}{
+			"type": gjson.Get(txx.Before, "_type").String(),
+		}
+	}
+	return reference
+}
+
+// translationReference builds a translations[] item of a translation.metadata document
+func translationReference(lang string, documentID string) map[string]interface{} {
+	return map[string]interface{}{
+		"_key":  lang,
+		"_type": "internationalizedArrayReferenceValue",
+		"value": map[string]interface{}{
+			"_ref":  documentID,
+			"_type": "reference",
+		},
+	}
 }
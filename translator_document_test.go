@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -59,7 +60,7 @@ func TestSanityTranslateDocumentTranslationMetadataEmpty(t *testing.T) {
 		*[slug.current == '%s']{title}`,
 		translated_slug,
 	)
-	r_translated_document, err := RunQuery(translated_query)
+	r_translated_document, err := RunQuery(translated_query, nil)
 	title := gjson.Get(r_translated_document, "result.0.title").String()
 	if title != "Questo è un titolo di prova" {
 		t.Fatalf("Expected title to be 'Questo è un titolo di prova', got %v", title)
@@ -70,7 +71,7 @@ func TestSanityTranslateDocumentTranslationMetadataEmpty(t *testing.T) {
 		*[_id == '%s']`,
 		original_doc_id+"_base",
 	)
-	r_metadata, err := RunQuery(metadata_query)
+	r_metadata, err := RunQuery(metadata_query, nil)
 
 	// // Check for the Italian translation
 	id_IT := gjson.Get(r_metadata, "result.0.translations.1.value._ref").String()
@@ -78,7 +79,7 @@ func TestSanityTranslateDocumentTranslationMetadataEmpty(t *testing.T) {
 		*[_id == '%s']{"slug": slug.current}`,
 		id_IT,
 	)
-	r_IT_document, err := RunQuery(IT_query)
+	r_IT_document, err := RunQuery(IT_query, nil)
 	slug_IT := gjson.Get(r_IT_document, "result.0.slug").String()
 	if slug_IT != translated_slug {
 		t.Fatalf("Expected slug to be '%s', got %v", translated_slug, slug_IT)
@@ -90,7 +91,7 @@ func TestSanityTranslateDocumentTranslationMetadataEmpty(t *testing.T) {
 		*[_id == '%s']{"slug": slug.current}`,
 		id_EN,
 	)
-	r_EN_document, err := RunQuery(EN_query)
+	r_EN_document, err := RunQuery(EN_query, nil)
 	slug_EN := gjson.Get(r_EN_document, "result.0.slug").String()
 	if slug_EN != original_slug {
 		t.Fatalf("Expected slug to be '%s', got %v", original_slug, slug_EN)
@@ -162,7 +163,7 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 		second_translated_slug,
 	)
 
-	response, err := RunQuery(second_translated_query)
+	response, err := RunQuery(second_translated_query, nil)
 	title := gjson.Get(response, "result.0.title").String()
 
 	// Check if the HTTP response is as expected
@@ -185,7 +186,7 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 		*[_id == '%s']`,
 		original_doc_id+"_base",
 	)
-	r_metadata, err := RunQuery(metadata_query)
+	r_metadata, err := RunQuery(metadata_query, nil)
 
 	// // Check for the English translation
 	id_EN := gjson.Get(r_metadata, "result.0.translations.0.value._ref").String()
@@ -193,7 +194,7 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 		*[_id == '%s']{"slug": slug.current}`,
 		id_EN,
 	)
-	r_EN_document, err := RunQuery(EN_query)
+	r_EN_document, err := RunQuery(EN_query, nil)
 	slug_EN := gjson.Get(r_EN_document, "result.0.slug").String()
 	if slug_EN != original_slug {
 		fmt.Println("CIAO 2")
@@ -206,7 +207,7 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 		*[_id == '%s']{"slug": slug.current}`,
 		id_IT,
 	)
-	r_IT_document, err := RunQuery(IT_query)
+	r_IT_document, err := RunQuery(IT_query, nil)
 	slug_IT := gjson.Get(r_IT_document, "result.0.slug").String()
 	if slug_IT != translated_slug {
 		fmt.Println("CIAO 1")
@@ -219,7 +220,7 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 		*[_id == '%s']{"slug": slug.current}`,
 		id_FR,
 	)
-	r_FR_document, err := RunQuery(FR_query)
+	r_FR_document, err := RunQuery(FR_query, nil)
 	slug_FR := gjson.Get(r_FR_document, "result.0.slug").String()
 	if slug_FR != second_translated_slug {
 		fmt.Println("CIAO 3")
@@ -228,46 +229,40 @@ func TestSanityTranslateDocumentTranslationMetadataNotEmpty(t *testing.T) {
 }
 
 func createTestDocument(doc_id string, slug string) (string, error) {
-	rawMutation := fmt.Sprintf(`
-	 	{
-	 		"mutations": [
-	 			{
-	 				"createOrReplace": {
-						"_id": "%s",
-						"language": "en",
-						"_type": "test",
-						"slug": {
-							"_type": "slug",
-							"current": "%s"
-						},
-						"title": "This is a test title",
-						"intro": "This is an example of text",
-						"portableTest": [
-							{
-								"_key": "c6280f5ed117",
-								"_type": "block",
-								"children": [
-									{
-										"_key": "e458c432651a",
-										"_type": "span",
-										"marks": [],
-										"text": "This is a test string"
-									}
-								],
-								"markDefs": [],
-								"style": "normal"
-							}
-						],
-						"testArray": [
-							"This is the first test string",
-							"This is the second test string"
-						]
-					}
+	document := fmt.Sprintf(`
+		{
+			"_id": "%s",
+			"language": "en",
+			"_type": "test",
+			"slug": {
+				"_type": "slug",
+				"current": "%s"
+			},
+			"title": "This is a test title",
+			"intro": "This is an example of text",
+			"portableTest": [
+				{
+					"_key": "c6280f5ed117",
+					"_type": "block",
+					"children": [
+						{
+							"_key": "e458c432651a",
+							"_type": "span",
+							"marks": [],
+							"text": "This is a test string"
+						}
+					],
+					"markDefs": [],
+					"style": "normal"
 				}
+			],
+			"testArray": [
+				"This is the first test string",
+				"This is the second test string"
 			]
 		}`, doc_id, slug)
 
-	err := RunMutation(rawMutation)
+	err := RunMutation(Mutation{CreateOrReplace: json.RawMessage(document)})
 	if err != nil {
 		return "", err
 	}
@@ -275,18 +270,7 @@ func createTestDocument(doc_id string, slug string) (string, error) {
 }
 
 func deleteTestDocument(doc_id string) error {
-	rawMutation := fmt.Sprintf(`
-		{
-			"mutations": [
-				{
-					"delete": {
-						"id": "%s"
-					}
-				}
-			]
-		}`, doc_id)
-
-	err := RunMutation(rawMutation)
+	err := RunMutation(Mutation{Delete: &Delete{ID: doc_id}})
 	if err != nil {
 		return err
 	}
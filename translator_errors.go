@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of failure a translation provider can report.
+var (
+	ErrQuotaExceeded       = errors.New("translation quota exceeded")
+	ErrAuthFailed          = errors.New("translation provider authentication failed")
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrRateLimited         = errors.New("translation provider rate limit reached")
+	ErrTransient           = errors.New("translation provider temporarily unavailable")
+)
+
+// TranslationError wraps a failure returned by a translation provider.
+type TranslationError struct {
+	Kind       error  // One of the Err* kinds above, nil when unclassified
+	Provider   string // Name of the provider that failed
+	StatusCode int    // HTTP status returned by the provider, 0 for network errors
+	Message    string // Message returned by the provider
+}
+
+func (e *TranslationError) Error() string {
+	kind := "translation failed"
+	if e.Kind != nil {
+		kind = e.Kind.Error()
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: %s (status %d): %s", e.Provider, kind, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Provider, kind, e.Message)
+}
+
+func (e *TranslationError) Unwrap() error {
+	return e.Kind
+}
+
+// translationErrorResponse maps a translation error to an HTTP status and error code.
+func translationErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusPaymentRequired, "quota_exceeded"
+	case errors.Is(err, ErrAuthFailed):
+		return http.StatusBadGateway, "provider_auth_failed"
+	case errors.Is(err, ErrUnsupportedLanguage):
+		return http.StatusUnprocessableEntity, "unsupported_language"
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, ErrTransient):
+		return http.StatusServiceUnavailable, "provider_unavailable"
+	default:
+		return http.StatusBadRequest, "translation_failed"
+	}
+}
+
+// AbortWithTranslationError writes a JSON error body whose status depends on the kind of err.
+func AbortWithTranslationError(c *gin.Context, message string, err error) {
+	status, code := translationErrorResponse(err)
+	fmt.Fprintf(logOutput, "%s: %v\n", message, err)
+	c.AbortWithStatusJSON(
+		status,
+		gin.H{
+			"status":  "error",
+			"code":    code,
+			"message": message,
+			"error":   err.Error(),
+		},
+	)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestDeeplClientTypedErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantKind   error
+		wantStatus int
+	}{
+		{
+			name:       "QuotaExceeded",
+			statusCode: 456,
+			body:       `{"message":"Quota exceeded"}`,
+			wantKind:   ErrQuotaExceeded,
+			wantStatus: http.StatusPaymentRequired,
+		},
+		{
+			name:       "AuthFailed",
+			statusCode: http.StatusForbidden,
+			body:       `{"message":"Wrong endpoint"}`,
+			wantKind:   ErrAuthFailed,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "UnsupportedLanguage",
+			statusCode: http.StatusBadRequest,
+			body:       `{"message":"Value for 'target_lang' not supported."}`,
+			wantKind:   ErrUnsupportedLanguage,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "RateLimited",
+			statusCode: http.StatusTooManyRequests,
+			body:       `{"message":"Too many requests"}`,
+			wantKind:   ErrRateLimited,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "Transient",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `Service Unavailable`,
+			wantKind:   ErrTransient,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewDeeplClient("test-key", server.URL)
+			client.Interval = time.Millisecond
+			_, err := client.Translate(context.Background(), "Hello", "EN", "IT", TranslationOptions{})
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("%s: Translate() error = %v, want %v", tt.name, err, tt.wantKind)
+			}
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			AbortWithTranslationError(c, "Failed executing translations", err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s: status = %v, want %v", tt.name, w.Code, tt.wantStatus)
+			}
+			if gjson.Get(w.Body.String(), "status").String() != "error" {
+				t.Errorf("%s: unexpected body %s", tt.name, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeeplRequestCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := NewDeeplClient("test-key", server.URL).TranslateBatch(ctx, []string{"Hello"}, "EN", "IT", TranslationOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TranslateBatch() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDeeplClientRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		limited := len(requests) == 2
+		mu.Unlock()
+		if limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"translations":[{"text":"Ciao"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewDeeplClient("test-key", server.URL)
+	client.Interval = 20 * time.Millisecond
+
+	// Concurrent calls share the interval, and the request answered 429 is retried
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.TranslateBatch(context.Background(), []string{"Hello"}, "EN", "IT", TranslationOptions{}); err != nil {
+				t.Errorf("TranslateBatch() returned an error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(requests) != 4 {
+		t.Fatalf("sent %d requests, want 4", len(requests))
+	}
+	for i := 1; i < len(requests); i++ {
+		if gap := requests[i].Sub(requests[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("request %d sent %s after the previous one", i, gap)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -17,26 +18,84 @@ func convertSanityPathToGJSONPath(sanityPath string) string {
 	return re.ReplaceAllString(sanityPath, ".$1")
 }
 
+// slugLanguagePattern matches the language prefix of a slug, e.g. /it/ or /pt-br/.
+var slugLanguagePattern = regexp.MustCompile(`^/([a-zA-Z]{2}(-[a-zA-Z]{2,4})?)(/|$)`)
+
+// slugLanguage returns the language of a translated document from its slug (/it/page is "it").
+func slugLanguage(slug string) (string, error) {
+	match := slugLanguagePattern.FindStringSubmatch(slug)
+	if match == nil {
+		return "", fmt.Errorf("invalid ToSlugs: %q does not start with a language, e.g. /it/page", slug)
+	}
+	return strings.ToLower(match[1]), nil
+}
+
 // SanityTranslateField handles the main logic for translating a specific field in Sanity documents.
 func SanityTranslateField(c *gin.Context) {
 	var txx SanityFieldTranslator
 
-	fmt.Println("Translating field")
+	fmt.Fprintln(logOutput, "Translating field")
 
 	// Create a Translator object adding all the info from the request
 	if err := c.BindJSON(&txx); err != nil {
 		c.String(http.StatusBadRequest, "Failed binding event to JSON")
-		fmt.Println("Failed binding event to JSON")
+		fmt.Fprintln(logOutput, "Failed binding event to JSON")
 		return
 	}
 
+	translations, err := TranslateField(&txx)
+	if err != nil {
+		RespondStepError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"status":       "success",
+			"message":      "Field translation completed",
+			"translations": translations,
+		},
+	)
+}
+
+// FieldTranslation is a field written by TranslateField.
+type FieldTranslation struct {
+	Path       string `json:"path"`
+	ToSlug     string `json:"toSlug"`
+	DocumentID string `json:"documentId"`
+	Translated string `json:"translated"`
+}
+
+// TranslateField translates the mapped fields of the source document and patches
+// them into every document of txx.ToSlugs.
+func TranslateField(txx *SanityFieldTranslator) ([]FieldTranslation, error) {
+	translations := []FieldTranslation{}
+
+	if err := txx.Options.Validate(); err != nil {
+		return translations, stepFailed("invalid Options: "+err.Error(), err)
+	}
+	toLangs := make([]string, len(txx.ToSlugs))
+	languages := map[string]bool{}
+	for i, toSlug := range txx.ToSlugs {
+		lang, err := slugLanguage(toSlug)
+		if err != nil {
+			return translations, stepFailed(err.Error(), err)
+		}
+		toLangs[i] = lang
+		languages[lang] = true
+	}
+	if err := validateGlossaryID(txx.GlossaryID, len(languages)); err != nil {
+		return translations, stepFailed(err.Error(), err)
+	}
+
+	sanity := sanityFor(txx.Dataset)
+
 	// Create a SanityDocument object adding all the info from Sanity API
-	query := fmt.Sprintf(`*[slug.current == '%s'][0]`, txx.FromSlug)
-	originalDocument, err := RunQuery(query)
+	query := `*[slug.current == $slug][0]`
+	originalDocument, err := sanity.Query(query, map[string]interface{}{"slug": txx.FromSlug})
 	if err != nil || originalDocument == "" {
-		c.String(http.StatusBadRequest, "Error extracting original_doc from Sanity")
-		fmt.Println("Error extracting original_doc from Sanity")
-		return
+		return translations, stepFailed("Error extracting original_doc from Sanity", err)
 	}
 	result := gjson.Get(originalDocument, "result").Raw
 	txx.Id = gjson.Get(result, "_id").String()
@@ -45,70 +104,59 @@ func SanityTranslateField(c *gin.Context) {
 	for _, mappingField := range txx.MappingFields {
 		gjsonPath := convertSanityPathToGJSONPath(mappingField.SanityPath)
 
-		fieldValue := gjson.Get(txx.Before, gjsonPath).String()
+		field := gjson.Get(txx.Before, gjsonPath)
+		fieldValue := field.String()
 		if fieldValue == "" {
-			c.String(http.StatusBadRequest, "Field not found in the document")
-			fmt.Println("Field not found in the document")
-			return
+			return translations, stepFailed("Field not found in the document", nil)
+		}
+		if field.Type != gjson.String {
+			return translations, stepFailed("Field is not a string: "+mappingField.SanityPath, nil)
+		}
+		if reason := txx.LeafRules.SkipReason(gjsonPath, fieldValue); reason != "" {
+			return translations, stepFailed(fmt.Sprintf("Field %s is not translatable: %s", mappingField.SanityPath, reason), nil)
 		}
 
-		for _, toSlug := range txx.ToSlugs {
-			query = fmt.Sprintf(`*[slug.current == '%s'][0]`, toSlug)
-			translatedDoc, err := RunQuery(query)
+		for i, toSlug := range txx.ToSlugs {
+			translatedDoc, err := sanity.WithoutCDN().Query(query, map[string]interface{}{"slug": toSlug})
 			if err != nil {
-				c.String(http.StatusBadRequest, "Error extracting translated_doc from Sanity")
-				fmt.Println("Error extracting translated_doc from Sanity")
-				return
+				return translations, stepFailed("Error extracting translated_doc from Sanity", err)
 			}
 			translatedDocResult := gjson.Get(translatedDoc, "result").Raw
 			translatedDocID := gjson.Get(translatedDocResult, "_id").String()
 
-			translatedToLang := toSlug[1:3]
-			translatedValue, err := RunDeepl(fieldValue, txx.FromLang, translatedToLang)
+			translatedToLang := toLangs[i]
+			opts := txx.Options
+			opts.GlossaryID = ResolveGlossaryID(txx.GlossaryID, txx.Glossaries, txx.FromLang, translatedToLang)
+			translatedValue, err := ActiveTranslator.Translate(context.Background(), fieldValue, txx.FromLang, translatedToLang, opts)
 			if err != nil {
-				c.String(http.StatusBadRequest, "Failed executing translation")
-				fmt.Println("Failed executing translation")
-				return
+				return translations, &StepError{Message: "Failed executing translation", Err: err}
 			}
 
 			translatedValue = strings.TrimSpace(translatedValue)
 
-			rawPatch := fmt.Sprintf(`
-			{
-				"mutations": [
-					{
-						"patch": {
-							"id": "%s",
-							"set": {
-								"%s": "%s"
-							}
-						}
-					}
-				]
-			}`,
-				translatedDocID,
-				mappingField.SanityPath,
-				translatedValue,
+			_, err = sanity.Mutate(
+				Mutation{
+					Patch: &Patch{
+						ID: translatedDocID,
+						Set: map[string]interface{}{
+							mappingField.SanityPath: translatedValue,
+						},
+					},
+				},
 			)
-
-			err = RunMutation(rawPatch)
 			if err != nil {
-				errorMsg := fmt.Sprintf("Failed patching translated field: %v", err)
-				c.String(http.StatusBadRequest, errorMsg)
-				fmt.Println(errorMsg)
-				return
+				return translations, stepFailed(fmt.Sprintf("Failed patching translated field: %v", err), err)
 			}
 
-			fmt.Printf("\tTranslating field: %s\n", toSlug)
+			translations = append(translations, FieldTranslation{
+				Path:       mappingField.SanityPath,
+				ToSlug:     toSlug,
+				DocumentID: translatedDocID,
+				Translated: translatedValue,
+			})
+			fmt.Fprintf(logOutput, "\tTranslating field: %s\n", toSlug)
 		}
-		fmt.Println("")
+		fmt.Fprintln(logOutput, "")
 	}
-
-	c.JSON(
-		http.StatusOK,
-		gin.H{
-			"status":  "success",
-			"message": "Field translation completed",
-		},
-	)
+	return translations, nil
 }
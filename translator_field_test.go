@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestSanityTranslateFieldTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		toSlugs    string
+		glossaryID string
+		wantCode   int
+	}{
+		{name: "LanguagePrefix", toSlugs: `"/it/doc"`, wantCode: http.StatusOK},
+		{name: "RegionPrefix", toSlugs: `"/pt-br/doc"`, wantCode: http.StatusOK},
+		{name: "LanguageOnly", toSlugs: `"/it"`, wantCode: http.StatusOK},
+		{name: "NoSlash", toSlugs: `"it"`, wantCode: http.StatusBadRequest},
+		{name: "NoLanguage", toSlugs: `"/about"`, wantCode: http.StatusBadRequest},
+		{name: "GlossarySameLanguage", toSlugs: `"/it/a", "/it/b"`, glossaryID: "g-1", wantCode: http.StatusOK},
+		{name: "GlossarySeveralLanguages", toSlugs: `"/it/a", "/de/a"`, glossaryID: "g-1", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake *fakeSanity
+			fake = useFakeSanity(t, func(query string) string {
+				slug := strings.Trim(fake.params.Get("$slug"), `"`)
+				return `{"_id": "` + strings.Trim(slug, "/") + `", "_type": "test", "title": "Title"}`
+			})
+			useFakeTranslator(t)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/sanity_translate_field", SanityTranslateField)
+			body := `{"FromLang": "en", "FromSlug": "/en/doc", "ToSlugs": [` + tt.toSlugs + `], "GlossaryID": "` + tt.glossaryID + `",
+				"MappingFields": [{"JsonPath": "title", "SanityPath": "title"}]}`
+			req := httptest.NewRequest("POST", "/sanity_translate_field", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				if len(fake.mutations) != 0 {
+					t.Errorf("%s: sent %d mutate requests, want none", tt.name, len(fake.mutations))
+				}
+				return
+			}
+			if got := gjson.Get(w.Body.String(), "translations.0.translated").String(); got != "IT: Title" {
+				t.Errorf("%s: translated = %q, want %q", tt.name, got, "IT: Title")
+			}
+		})
+	}
+}
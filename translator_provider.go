@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Translator is implemented by every translation engine the service can use.
+type Translator interface {
+	Name() string
+	// Translate and TranslateBatch stop when ctx is cancelled, returning ctx.Err().
+	Translate(ctx context.Context, text string, fromLang string, toLang string, opts TranslationOptions) (string, error)
+	TranslateBatch(ctx context.Context, texts []string, fromLang string, toLang string, opts TranslationOptions) ([]string, error)
+	SupportedLanguages() ([]Language, error)
+	Usage() (Usage, error)
+}
+
+// TranslationOptions holds the per-request settings forwarded to the provider.
+// Empty fields leave the provider default.
+type TranslationOptions struct {
+	GlossaryID     string `json:"-"` // Glossary to apply, set from the request GlossaryID
+	Formality      string // default, more, less, prefer_more or prefer_less
+	SplitSentences string // 0, 1 or nonewlines
+	Context        string // Extra text that guides the translation without being translated
+	TagHandling    string `json:"-"` // Markup of the text (xml), set for Portable Text blocks
+}
+
+// Validate checks that every option holds a supported value.
+func (o TranslationOptions) Validate() error {
+	switch o.Formality {
+	case "", "default", "more", "less", "prefer_more", "prefer_less":
+	default:
+		return fmt.Errorf("unsupported formality: %s", o.Formality)
+	}
+	switch o.SplitSentences {
+	case "", "0", "1", "nonewlines":
+	default:
+		return fmt.Errorf("unsupported split_sentences: %s", o.SplitSentences)
+	}
+	return nil
+}
+
+// Merge returns o with every non-empty field of override applied on top.
+func (o TranslationOptions) Merge(override TranslationOptions) TranslationOptions {
+	if override.GlossaryID != "" {
+		o.GlossaryID = override.GlossaryID
+	}
+	if override.Formality != "" {
+		o.Formality = override.Formality
+	}
+	if override.SplitSentences != "" {
+		o.SplitSentences = override.SplitSentences
+	}
+	if override.Context != "" {
+		o.Context = override.Context
+	}
+	if override.TagHandling != "" {
+		o.TagHandling = override.TagHandling
+	}
+	return o
+}
+
+// Language is a language supported by a translation provider.
+type Language struct {
+	Code string
+	Name string
+}
+
+// Usage reports the consumption of a translation provider account.
+type Usage struct {
+	CharacterCount int64
+	CharacterLimit int64
+}
+
+// ActiveTranslator is the provider used by the HTTP handlers.
+var ActiveTranslator Translator = NewDeeplClient(
+	os.Getenv("DEEPL_TOKEN"),
+	os.Getenv("DEEPL_API_URL"),
+)
+
+// NewTranslator returns the provider matching the given name (defaults to DeepL).
+func NewTranslator(name string) (Translator, error) {
+	switch name {
+	case "", "deepl":
+		return NewDeeplClient(
+			os.Getenv("DEEPL_TOKEN"),
+			os.Getenv("DEEPL_API_URL"),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider: %s", name)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTranslator is an in-memory Translator used to avoid calling real providers.
+type fakeTranslator struct {
+	mu       sync.Mutex
+	prefix   string
+	failLang string // Language whose translations fail
+	batches  int
+	options  []TranslationOptions // Options received by each TranslateBatch call
+}
+
+func (f *fakeTranslator) Name() string { return "fake" }
+
+func (f *fakeTranslator) Translate(ctx context.Context, text string, fromLang string, toLang string, opts TranslationOptions) (string, error) {
+	return f.prefix + text, nil
+}
+
+func (f *fakeTranslator) TranslateBatch(ctx context.Context, texts []string, fromLang string, toLang string, opts TranslationOptions) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failLang != "" && strings.EqualFold(toLang, f.failLang) {
+		return nil, fmt.Errorf("translation to %s failed", toLang)
+	}
+	f.batches++
+	f.options = append(f.options, opts)
+	translations := make([]string, len(texts))
+	for i, text := range texts {
+		translations[i] = f.prefix + text
+	}
+	return translations, nil
+}
+
+func (f *fakeTranslator) SupportedLanguages() ([]Language, error) {
+	return []Language{{Code: "IT", Name: "Italian"}}, nil
+}
+
+func (f *fakeTranslator) Usage() (Usage, error) {
+	return Usage{}, nil
+}
+
+func TestNewTranslator(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "DefaultProvider", provider: "", wantName: "deepl"},
+		{name: "DeeplProvider", provider: "deepl", wantName: "deepl"},
+		{name: "UnknownProvider", provider: "babelfish", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translator, err := NewTranslator(tt.provider)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%s: NewTranslator() expected an error", tt.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: NewTranslator() returned an error: %v", tt.name, err)
+			}
+			if translator.Name() != tt.wantName {
+				t.Errorf("%s: NewTranslator() = %v, want %v", tt.name, translator.Name(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestDeeplClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/translate":
+			r.ParseForm()
+			if len(r.PostForm["text"]) == 2 {
+				w.Write([]byte(`{"translations":[{"text":"Ciao"},{"text":"mondo"}]}`))
+				return
+			}
+			w.Write([]byte(`{"translations":[{"text":"Ciao mondo"}]}`))
+		case "/languages":
+			w.Write([]byte(`[{"language":"IT","name":"Italian"}]`))
+		case "/usage":
+			w.Write([]byte(`{"character_count":42,"character_limit":500000}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewDeeplClient("test-key", server.URL)
+	client.Interval = time.Millisecond
+
+	translated, err := client.Translate(context.Background(), "Hello world ", "EN", "IT", TranslationOptions{})
+	if err != nil {
+		t.Fatalf("Translate() returned an error: %v", err)
+	}
+	if translated != "Ciao mondo " {
+		t.Errorf("Translate() = %q, want %q", translated, "Ciao mondo ")
+	}
+
+	batch, err := client.TranslateBatch(context.Background(), []string{"Hello", " world"}, "EN", "IT", TranslationOptions{})
+	if err != nil {
+		t.Fatalf("TranslateBatch() returned an error: %v", err)
+	}
+	if len(batch) != 2 || batch[0] != "Ciao" || batch[1] != " mondo" {
+		t.Errorf("TranslateBatch() = %q, want [Ciao  mondo]", batch)
+	}
+
+	languages, err := client.SupportedLanguages()
+	if err != nil {
+		t.Fatalf("SupportedLanguages() returned an error: %v", err)
+	}
+	if len(languages) != 1 || languages[0].Code != "IT" {
+		t.Errorf("SupportedLanguages() = %v, want [IT]", languages)
+	}
+
+	usage, err := client.Usage()
+	if err != nil {
+		t.Fatalf("Usage() returned an error: %v", err)
+	}
+	if usage.CharacterCount != 42 || usage.CharacterLimit != 500000 {
+		t.Errorf("Usage() = %+v, want 42/500000", usage)
+	}
+}
+
+func TestTranslateFieldsBatchesAllFields(t *testing.T) {
+	fake := &fakeTranslator{prefix: "IT: "}
+	originalTranslator := ActiveTranslator
+	ActiveTranslator = fake
+	defer func() { ActiveTranslator = originalTranslator }()
+
+	txx := SanityDocumentTranslator{
+		FromLang:      "en",
+		ToLang:        "it",
+		InputElements: []InputElement{{Path: "title"}, {Path: "text.000.children.000.text"}},
+	}
+	document := map[string]interface{}{
+		"title": "This is a test title",
+		"intro": "This is an example of text",
+		"text": []interface{}{
+			map[string]interface{}{
+				"children": []interface{}{
+					map[string]interface{}{"text": "First span"},
+					map[string]interface{}{"text": "Second span"},
+				},
+			},
+		},
+	}
+
+	err := ExecuteTranslation(&txx, document, "")
+	if err != nil {
+		t.Fatalf("ExecuteTranslation() returned an error: %v", err)
+	}
+	err = TranslateFields(context.Background(), &txx)
+	if err != nil {
+		t.Fatalf("TranslateFields() returned an error: %v", err)
+	}
+
+	if fake.batches != 1 {
+		t.Errorf("TranslateFields() sent %d batches, want 1", fake.batches)
+	}
+	if len(txx.Fields) != 3 {
+		t.Fatalf("ExecuteTranslation() collected %d fields, want 3", len(txx.Fields))
+	}
+	for _, field := range txx.Fields {
+		if field.TranslatedContent != "IT: "+field.OriginalContent {
+			t.Errorf("%s: got %q, want %q", field.Path, field.TranslatedContent, "IT: "+field.OriginalContent)
+		}
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		maxTexts int
+		maxBytes int
+		want     []int
+	}{
+		{name: "Empty", texts: []string{}, maxTexts: 2, maxBytes: 20, want: []int{}},
+		{name: "SingleBatch", texts: []string{"a", "b"}, maxTexts: 2, maxBytes: 20, want: []int{2}},
+		{name: "BoundedByCount", texts: []string{"a", "b", "c"}, maxTexts: 2, maxBytes: 30, want: []int{2, 1}},
+		{name: "BoundedBySize", texts: []string{"aaaa", "bbbb", "cc"}, maxTexts: 5, maxBytes: 18, want: []int{1, 2}},
+		{name: "OversizedText", texts: []string{"aaaaaaaaaa", "b"}, maxTexts: 5, maxBytes: 12, want: []int{1, 1}},
+		// "日本" is 6 bytes but 18 once percent-encoded
+		{name: "EncodedSize", texts: []string{"日本", "日本"}, maxTexts: 5, maxBytes: 30, want: []int{1, 1}},
+		{name: "EncodedTags", texts: []string{"<p>a</p>", "<p>b</p>"}, maxTexts: 5, maxBytes: 40, want: []int{1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := splitBatches(tt.texts, tt.maxTexts, tt.maxBytes)
+			if len(batches) != len(tt.want) {
+				t.Fatalf("%s: splitBatches() = %v, want sizes %v", tt.name, batches, tt.want)
+			}
+			for i, batch := range batches {
+				if len(batch) != tt.want[i] {
+					t.Errorf("%s: batch %d has %d texts, want %d", tt.name, i, len(batch), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitBatchesRequestSize(t *testing.T) {
+	texts := make([]string, 13)
+	for i := range texts {
+		texts[i] = strings.Repeat("翻", 3*1024) // 9 KiB, 27 KiB encoded
+	}
+	for i, batch := range splitBatches(texts, DeeplMaxBatchTexts, DeeplMaxBatchBytes) {
+		values := url.Values{"text": batch, "source_lang": {"EN"}, "target_lang": {"IT"}}
+		if size := len(values.Encode()); size > 128*1024 {
+			t.Errorf("batch %d encodes to %d bytes, over the 128 KiB request limit", i, size)
+		}
+	}
+}
+
+func TestDeeplAPIURLForKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		authKey string
+		apiURL  string
+		want    string
+	}{
+		{name: "FreeKey", authKey: "abc-123:fx", want: DeeplFreeAPIURL},
+		{name: "ProKey", authKey: "abc-123", want: DeeplProAPIURL},
+		{name: "ExplicitURL", authKey: "abc-123:fx", apiURL: "http://localhost:9999/v2/", want: "http://localhost:9999/v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewDeeplClient(tt.authKey, tt.apiURL)
+			if client.APIURL != tt.want {
+				t.Errorf("%s: APIURL = %v, want %v", tt.name, client.APIURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateFieldsPerPathOptions(t *testing.T) {
+	fake := &fakeTranslator{prefix: "DE: "}
+	originalTranslator := ActiveTranslator
+	ActiveTranslator = fake
+	defer func() { ActiveTranslator = originalTranslator }()
+
+	var txx SanityDocumentTranslator
+	err := json.Unmarshal([]byte(`
+		{
+			"FromLang": "en",
+			"ToLang": "de",
+			"Options": {"Formality": "prefer_more"},
+			"InputElements": [
+				"title",
+				{"Path": "cta", "Options": {"Formality": "prefer_less"}}
+			]
+		}`), &txx)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal request: %v", err)
+	}
+	if err := ValidateDocumentRequest(&txx); err != nil {
+		t.Fatalf("ValidateDocumentRequest() returned an error: %v", err)
+	}
+
+	document := map[string]interface{}{
+		"title": "Welcome",
+		"cta":   "Buy now",
+	}
+	if err := ExecuteTranslation(&txx, document, ""); err != nil {
+		t.Fatalf("ExecuteTranslation() returned an error: %v", err)
+	}
+	if err := TranslateFields(context.Background(), &txx); err != nil {
+		t.Fatalf("TranslateFields() returned an error: %v", err)
+	}
+
+	if fake.batches != 2 {
+		t.Fatalf("TranslateFields() sent %d batches, want 2", fake.batches)
+	}
+	formalities := map[string]bool{}
+	for _, opts := range fake.options {
+		formalities[opts.Formality] = true
+	}
+	if !formalities["prefer_more"] || !formalities["prefer_less"] {
+		t.Errorf("TranslateFields() options = %+v", fake.options)
+	}
+}
+
+func TestTranslationOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    TranslationOptions
+		wantErr bool
+	}{
+		{name: "Empty", opts: TranslationOptions{}},
+		{name: "Valid", opts: TranslationOptions{Formality: "prefer_more", SplitSentences: "nonewlines", Context: "Landing page"}},
+		{name: "InvalidFormality", opts: TranslationOptions{Formality: "casual"}, wantErr: true},
+		{name: "InvalidSplitSentences", opts: TranslationOptions{SplitSentences: "2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}